@@ -0,0 +1,86 @@
+package cli
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+func (a Arg) kind() FlagType {
+	return Flag{Type: a.Type, Default: a.Default}.kind()
+}
+
+// convert turns a raw argument into its typed value, checking Choices and
+// Validate along the way.
+func (a Arg) convert(raw string) (any, error) {
+	if len(a.Choices) > 0 && !slices.Contains(a.Choices, raw) {
+		return nil, fmt.Errorf("invalid value %q for argument <%s>: must be one of %s", raw, a.Name, strings.Join(a.Choices, ", "))
+	}
+
+	var v any
+	if a.Parser != nil {
+		parsed, err := a.Parser(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %q for argument <%s>: %v", raw, a.Name, err)
+		}
+		v = parsed
+	} else {
+		value, err := Flag{Name: a.Name, Type: a.kind(), Choices: a.Choices}.newValue()
+		if err != nil {
+			return nil, fmt.Errorf("argument <%s>: %v", a.Name, err)
+		}
+		if err := value.Set(raw); err != nil {
+			return nil, fmt.Errorf("invalid value %q for argument <%s>: %v", raw, a.Name, err)
+		}
+		v = value.Get()
+	}
+
+	if a.Validate != nil {
+		if err := a.Validate(v); err != nil {
+			return nil, fmt.Errorf("invalid value %q for argument <%s>: %v", raw, a.Name, err)
+		}
+	}
+
+	return v, nil
+}
+
+// convertArgs maps every declared argument name to its typed value. Variadic
+// arguments map to a []any, missing optional arguments to their Default
+// converted to the argument's type, or are left out when there is none.
+func convertArgs(cmd *Command, parsed []string) (map[string]any, error) {
+	values := map[string]any{}
+
+	for i, a := range cmd.Args {
+		if a.Variadic {
+			var rest []any
+			for _, raw := range parsed[min(i, len(parsed)):] {
+				v, err := a.convert(raw)
+				if err != nil {
+					return nil, err
+				}
+				rest = append(rest, v)
+			}
+			values[a.Name] = rest
+			break
+		}
+
+		if i < len(parsed) {
+			v, err := a.convert(parsed[i])
+			if err != nil {
+				return nil, err
+			}
+			values[a.Name] = v
+			continue
+		}
+
+		if a.Default != nil {
+			v, err := a.convert(formatDefault(a.Default))
+			if err != nil {
+				return nil, fmt.Errorf("argument <%s>: invalid default: %w", a.Name, err)
+			}
+			values[a.Name] = v
+		}
+	}
+
+	return values, nil
+}
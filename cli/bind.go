@@ -0,0 +1,287 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// boundField maps a struct field to the flag or argument that fills it.
+type boundField struct {
+	field reflect.StructField
+	index []int
+	name  string
+	arg   bool
+}
+
+// CommandFromStruct returns a command named name whose flags and arguments
+// are declared by the fields of T. See Bind for the tags.
+func CommandFromStruct[T any](name string, handler func(ctx context.Context, in T) error) (*Command, error) {
+	cmd := &Command{Name: name}
+	if err := Bind(cmd, handler); err != nil {
+		return nil, err
+	}
+	return cmd, nil
+}
+
+// Bind declares flags and arguments on cmd from the fields of the struct T
+// and sets a handler that receives a T populated from them.
+//
+// Fields tagged `flag:"name,short"` become flags and fields tagged
+// `arg:"name"` become positional arguments, in field order; a slice argument
+// is variadic. Other tags refine them:
+//
+//	env:"A,B"       environment variables for a flag
+//	default:"v"     default value, in the syntax the flag accepts
+//	usage:"text"    flag usage or argument description
+//	type:"path"     FlagType, for types that cannot be told from the field
+//	choices:"a,b"   allowed values, making a string flag an EnumFlag
+//	required:"true" the flag must be set
+//	optional:"true" the argument may be left out
+//	hidden:"true"   the flag is not shown in help
+//
+// Untagged fields are ignored, except embedded structs, whose fields are
+// bound as if they were declared in T.
+func Bind[T any](cmd *Command, handler func(ctx context.Context, in T) error) error {
+	typ := reflect.TypeFor[T]()
+	if typ.Kind() != reflect.Struct {
+		return fmt.Errorf("bind %s: %s is not a struct", cmd.Name, typ)
+	}
+
+	var fields []boundField
+	if err := structFields(typ, nil, &fields); err != nil {
+		return fmt.Errorf("bind %s: %w", cmd.Name, err)
+	}
+	for _, f := range fields {
+		if f.arg {
+			a, err := structArg(f.field, f.name)
+			if err != nil {
+				return fmt.Errorf("bind %s: %w", cmd.Name, err)
+			}
+			cmd.Args = append(cmd.Args, a)
+			continue
+		}
+		fl, err := structFlag(f.field, f.name)
+		if err != nil {
+			return fmt.Errorf("bind %s: %w", cmd.Name, err)
+		}
+		cmd.FlagDefs = append(cmd.FlagDefs, fl)
+	}
+
+	cmd.Handler = func(ctx context.Context) error {
+		in, err := populate[T](ctx, fields)
+		if err != nil {
+			return err
+		}
+		return handler(ctx, in)
+	}
+	return nil
+}
+
+// structFields collects the tagged fields of typ and of the structs it
+// embeds, in field order.
+func structFields(typ reflect.Type, index []int, fields *[]boundField) error {
+	for i := 0; i < typ.NumField(); i++ {
+		sf := typ.Field(i)
+		idx := append(index[:len(index):len(index)], i)
+
+		flagTag, isFlag := sf.Tag.Lookup("flag")
+		argTag, isArg := sf.Tag.Lookup("arg")
+
+		switch {
+		case isFlag && isArg:
+			return fmt.Errorf("field %s: both flag and arg tags", sf.Name)
+		case isFlag, isArg:
+			name, _, _ := strings.Cut(flagTag+argTag, ",")
+			if name == "" {
+				return fmt.Errorf("field %s: empty name", sf.Name)
+			}
+			*fields = append(*fields, boundField{field: sf, index: idx, name: name, arg: isArg})
+		case sf.Anonymous && sf.Type.Kind() == reflect.Struct:
+			if err := structFields(sf.Type, idx, fields); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func structFlag(sf reflect.StructField, name string) (Flag, error) {
+	_, short, _ := strings.Cut(sf.Tag.Get("flag"), ",")
+	f := Flag{
+		Name:  name,
+		Short: short,
+		Usage: sf.Tag.Get("usage"),
+	}
+	if env := sf.Tag.Get("env"); env != "" {
+		f.Env = strings.Split(env, ",")
+	}
+	if choices := sf.Tag.Get("choices"); choices != "" {
+		f.Choices = strings.Split(choices, ",")
+	}
+	if def, ok := sf.Tag.Lookup("default"); ok {
+		f.Default = def
+	}
+
+	var err error
+	if f.Type, err = fieldType(sf, sf.Type); err != nil {
+		return f, err
+	}
+	if f.Type == StringFlag && len(f.Choices) > 0 {
+		f.Type = EnumFlag
+	}
+	if f.Required, err = boolTag(sf, "required"); err != nil {
+		return f, err
+	}
+	if f.Hidden, err = boolTag(sf, "hidden"); err != nil {
+		return f, err
+	}
+	return f, nil
+}
+
+func structArg(sf reflect.StructField, name string) (Arg, error) {
+	a := Arg{
+		Name:        name,
+		Description: sf.Tag.Get("usage"),
+	}
+	if choices := sf.Tag.Get("choices"); choices != "" {
+		a.Choices = strings.Split(choices, ",")
+	}
+	if def, ok := sf.Tag.Lookup("default"); ok {
+		a.Default = def
+	}
+
+	typ := sf.Type
+	if typ.Kind() == reflect.Slice {
+		a.Variadic = true
+		typ = typ.Elem()
+	}
+
+	var err error
+	if a.Type, err = fieldType(sf, typ); err != nil {
+		return a, err
+	}
+	if a.Optional, err = boolTag(sf, "optional"); err != nil {
+		return a, err
+	}
+	return a, nil
+}
+
+// fieldType picks the FlagType for a field from its type tag or Go type.
+func fieldType(sf reflect.StructField, typ reflect.Type) (FlagType, error) {
+	if t := sf.Tag.Get("type"); t != "" {
+		return FlagType(t), nil
+	}
+	switch typ {
+	case reflect.TypeFor[time.Duration]():
+		return DurationFlag, nil
+	case reflect.TypeFor[time.Time]():
+		return TimeFlag, nil
+	case reflect.TypeFor[*url.URL]():
+		return URLFlag, nil
+	case reflect.TypeFor[netip.Addr]():
+		return IPFlag, nil
+	case reflect.TypeFor[map[string]string]():
+		return StringMapFlag, nil
+	}
+	switch typ.Kind() {
+	case reflect.String:
+		return StringFlag, nil
+	case reflect.Bool:
+		return BoolFlag, nil
+	case reflect.Int:
+		return IntFlag, nil
+	case reflect.Float64:
+		return FloatFlag, nil
+	case reflect.Slice:
+		switch typ.Elem().Kind() {
+		case reflect.String:
+			return StringSliceFlag, nil
+		case reflect.Int:
+			return IntSliceFlag, nil
+		}
+	}
+	return "", fmt.Errorf("field %s: unsupported type %s", sf.Name, sf.Type)
+}
+
+func boolTag(sf reflect.StructField, key string) (bool, error) {
+	v, ok := sf.Tag.Lookup(key)
+	if !ok {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("field %s: invalid %s tag %q", sf.Name, key, v)
+	}
+	return b, nil
+}
+
+// populate fills a T from the flags and arguments of the running command.
+// Every tagged field must name a flag or argument the command declares.
+func populate[T any](ctx context.Context, fields []boundField) (T, error) {
+	var in T
+	v := reflect.ValueOf(&in).Elem()
+	flags := Flags(ctx)
+
+	for _, f := range fields {
+		raw, ok := flags[f.name]
+		what := "flag --" + f.name
+		if f.arg {
+			raw, ok = ArgValue(ctx, f.name), declaresArg(CurrentCommand(ctx), f.name)
+			what = "argument <" + f.name + ">"
+		}
+		if !ok {
+			return in, fmt.Errorf("%s is not defined", what)
+		}
+		if raw == nil {
+			continue
+		}
+		if err := setField(v.FieldByIndex(f.index), raw); err != nil {
+			return in, fmt.Errorf("%s: %w", what, err)
+		}
+	}
+	return in, nil
+}
+
+func declaresArg(cmd *Command, name string) bool {
+	if cmd == nil {
+		return false
+	}
+	for _, a := range cmd.Args {
+		if a.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// setField stores raw in dst, converting between named and unnamed types of
+// the same kind and element by element for []any.
+func setField(dst reflect.Value, raw any) error {
+	if items, ok := raw.([]any); ok && dst.Kind() == reflect.Slice {
+		s := reflect.MakeSlice(dst.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := setField(s.Index(i), item); err != nil {
+				return err
+			}
+		}
+		dst.Set(s)
+		return nil
+	}
+
+	rv := reflect.ValueOf(raw)
+	switch {
+	case rv.Type().AssignableTo(dst.Type()):
+		dst.Set(rv)
+	case rv.Kind() == dst.Kind() && rv.Type().ConvertibleTo(dst.Type()):
+		dst.Set(rv.Convert(dst.Type()))
+	default:
+		return fmt.Errorf("value is %T, not %s", raw, dst.Type())
+	}
+	return nil
+}
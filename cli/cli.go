@@ -2,13 +2,12 @@ package cli
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
-	"fmt"
 	"io"
 	"log"
 	"os"
-	"sort"
-	"strings"
+	"time"
 )
 
 type Handler func(ctx context.Context) error
@@ -22,6 +21,26 @@ type CLI struct {
 	hooks           map[HookPhase][]Hook
 	Middleware      []Middleware
 	HelpCommandName string
+	interspersed    bool
+	envPrefix       string
+	configPaths     []string
+	configFlag      string
+	configDecoders  map[string]ConfigDecoder
+
+	suggestionDistance int
+	noSuggestions      bool
+
+	completionCommand bool
+
+	helpCommand  *Command
+	helpRenderer HelpRenderer
+	helpWidth    int
+
+	handleSignals bool
+	signalGrace   time.Duration
+
+	// exit ends the process in RunAndExit; replaced in tests.
+	exit func(code int)
 }
 
 func New(root *Command, opts ...Option) *CLI {
@@ -39,13 +58,18 @@ func New(root *Command, opts ...Option) *CLI {
 	}
 
 	c := &CLI{
-		app:             app,
-		ctx:             context.Background(),
-		Root:            root,
-		out:             os.Stdout,
-		err:             os.Stderr,
-		hooks:           map[HookPhase][]Hook{},
-		HelpCommandName: "help",
+		app:                app,
+		ctx:                context.Background(),
+		Root:               root,
+		out:                os.Stdout,
+		err:                os.Stderr,
+		hooks:              map[HookPhase][]Hook{},
+		HelpCommandName:    "help",
+		exit:               os.Exit,
+		suggestionDistance: defaultSuggestionDistance,
+		configDecoders: map[string]ConfigDecoder{
+			".json": json.Unmarshal,
+		},
 	}
 
 	for _, opt := range opts {
@@ -54,7 +78,11 @@ func New(root *Command, opts ...Option) *CLI {
 
 	c.ctx = context.WithValue(c.ctx, appKey, c.app)
 
+	c.installConfigFlag()
 	c.installHelpCommand()
+	c.installCompletionCommand()
+	c.installConfigCommand()
+	c.installSpecCommand()
 
 	return c
 }
@@ -68,21 +96,46 @@ func (c *CLI) Context() context.Context {
 }
 
 func (c *CLI) Run(args []string) error {
+	return c.RunContext(c.ctx, args)
+}
+
+// RunContext is like Run but derives the context passed to hooks and
+// handlers from ctx, so cancelling ctx cancels the running command.
+func (c *CLI) RunContext(ctx context.Context, args []string) error {
+	ctx = context.WithValue(ctx, appKey, c.app)
+
+	// Completion requests bypass flag parsing and hooks so that nothing but
+	// candidates is written while the user presses TAB.
+	if c.completionCommand && len(args) > 0 && args[0] == completeCommandName {
+		return c.runComplete(ctx, args[1:])
+	}
+
+	if c.handleSignals {
+		var stop func(error) error
+		ctx, stop = c.notifySignals(ctx)
+		return stop(c.run(ctx, args))
+	}
+	return c.run(ctx, args)
+}
+
+func (c *CLI) run(ctx context.Context, args []string) error {
 	for _, h := range c.hooks[BeforeRun] {
-		if err := h(c.ctx); err != nil {
+		if err := h(ctx); err != nil {
 			return err
 		}
 	}
 
 	var runErr error
 	if len(args) == 0 {
-		runErr = c.printHelp(c.Root)
+		runErr = c.printHelp(c.Root, nil)
 	} else {
-		runErr = c.execute(c.ctx, c.Root, args, nil)
+		runErr = c.execute(ctx, c.Root, args, nil)
 	}
 
+	hookCtx, cancel := c.cleanupContext(ctx)
+	defer cancel()
 	for _, h := range c.hooks[AfterRun] {
-		if err := h(c.ctx); err != nil && runErr == nil {
+		if err := h(hookCtx); err != nil && runErr == nil {
 			runErr = err
 		}
 	}
@@ -91,40 +144,82 @@ func (c *CLI) Run(args []string) error {
 }
 
 func (c *CLI) execute(ctx context.Context, cmd *Command, args []string, parents []*Command) error {
-	if len(args) > 0 {
-		if sub := findSubcommand(cmd, args[0]); sub != nil {
-			return c.execute(ctx, sub, args[1:], append(parents, cmd))
-		}
+	depth := len(parents)
+	cmd, parents, args, words := resolveCommand(cmd, parents, args)
+
+	fs, showHelp, err := c.newFlagSet(cmd, parents)
+	if err != nil {
+		return err
 	}
 
-	fs := flag.NewFlagSet(cmd.Name, flag.ContinueOnError)
-	fs.SetOutput((c.err))
+	chain := append(parents[:len(parents):len(parents)], cmd)
 
-	showHelp := false
-	fs.BoolVar(&showHelp, "h", false, "show help")
-	fs.BoolVar(&showHelp, "help", false, "show help")
+	if err := fs.Parse(args); err != nil {
+		return usageError(chain, err)
+	}
 
-	if cmd.Flags != nil {
-		cmd.Flags(fs)
+	if *showHelp {
+		return c.printHelp(cmd, parents)
+	}
+	if e := fs.lookup(helpAllFlagName); e != nil && e.builtin && e.value.(*boolValue).v {
+		return c.renderHelp(cmd, parents, true)
 	}
 
-	if err := fs.Parse(args); err != nil {
+	if err := fs.applyEnv(); err != nil {
 		return err
 	}
 
-	if showHelp {
-		return c.printHelp(cmd)
+	if c.configEnabled() {
+		var explicit string
+		if e := fs.lookup(c.configFlag); c.configFlag != "" && e != nil {
+			explicit = e.value.String()
+		}
+		cfg, err := c.loadConfig(explicit)
+		if err != nil {
+			return err
+		}
+		if err := fs.applyConfig(cfg, chain); err != nil {
+			return err
+		}
 	}
 
 	parsedArgs := fs.Args()
 
+	// A command that only groups subcommands treats a stray word as a
+	// mistyped subcommand rather than silently printing help. Commands with
+	// a handler get undeclared words through Args.
+	if len(parsedArgs) > 0 && cmd.Handler == nil && len(cmd.Args) == 0 && hasVisibleCommands(cmd) {
+		return c.unknownCommandError(cmd, parents, parsedArgs[0])
+	}
+
 	if err := validatePositionalArgs(cmd, parsedArgs); err != nil {
-		return err
+		return usageError(chain, err)
 	}
 
+	// Built-in commands such as help inherit the root's persistent flags
+	// but must work without them, as must commands that only print help.
+	if !cmd.builtin && cmd.Handler != nil {
+		if err := validateRequiredFlags(fs); err != nil {
+			return usageError(chain, err)
+		}
+
+		if err := validateFlagGroups(chain, fs); err != nil {
+			return err
+		}
+	}
+
+	argValues, err := convertArgs(cmd, parsedArgs)
+	if err != nil {
+		return usageError(chain, err)
+	}
+
+	c.warnDeprecated(chain[depth:], words, fs)
+
 	ctx = context.WithValue(ctx, commandKey, cmd)
 	ctx = context.WithValue(ctx, argsKey, parsedArgs)
+	ctx = context.WithValue(ctx, argValuesKey, argValues)
 	ctx = context.WithValue(ctx, flagsKey, snapshotFlags(fs))
+	ctx = context.WithValue(ctx, flagSourcesKey, snapshotSources(fs))
 
 	for _, h := range c.hooks[BeforeCommand] {
 		if err := h(ctx); err != nil {
@@ -147,7 +242,7 @@ func (c *CLI) execute(ctx context.Context, cmd *Command, args []string, parents
 	}
 
 	if cmd.Handler == nil {
-		return c.printHelp(cmd)
+		return c.printHelp(cmd, parents)
 	}
 
 	final := cmd.Handler
@@ -157,7 +252,10 @@ func (c *CLI) execute(ctx context.Context, cmd *Command, args []string, parents
 	}
 	final = applyMiddleware(final, c.Middleware)
 
-	err := final(ctx)
+	err = final(ctx)
+
+	ctx, cancel := c.cleanupContext(ctx)
+	defer cancel()
 
 	for _, h := range cmd.After {
 		if hookErr := h(ctx); hookErr != nil && err == nil {
@@ -182,6 +280,42 @@ func (c *CLI) execute(ctx context.Context, cmd *Command, args []string, parents
 	return err
 }
 
+// resolveCommand walks down the command tree from cmd. Persistent flags of
+// the commands walked so far may precede a subcommand name; they are moved
+// in front of the remaining arguments to be parsed against the leaf. words
+// are the names or aliases that selected each command below cmd.
+func resolveCommand(cmd *Command, parents []*Command, args []string) (_ *Command, _ []*Command, rest, words []string) {
+	var leading []string
+	for len(args) > 0 {
+		if sub := findSubcommand(cmd, args[0]); sub != nil {
+			parents = append(parents, cmd)
+			cmd = sub
+			words = append(words, args[0])
+			args = args[1:]
+			continue
+		}
+
+		n := persistentFlagSpan(cmd, parents, args)
+		if n == 0 {
+			break
+		}
+		leading = append(leading, args[:n]...)
+		args = args[n:]
+	}
+	return cmd, parents, append(leading, args...), words
+}
+
+func (c *CLI) newFlagSet(cmd *Command, parents []*Command) (*flagSet, *bool, error) {
+	fs, showHelp, err := newFlagSet(cmd, parents)
+	if err != nil {
+		return nil, nil, err
+	}
+	fs.interspersed = c.interspersed || cmd.Interspersed
+	fs.envPrefix = c.envPrefix
+	fs.suggestDistance = c.suggestDistance()
+	return fs, showHelp, nil
+}
+
 func (c *CLI) installHelpCommand() {
 	if collides(c.Root, c.HelpCommandName) || c.Root.Name == c.HelpCommandName {
 		return
@@ -191,176 +325,49 @@ func (c *CLI) installHelpCommand() {
 		Name:        c.HelpCommandName,
 		Description: "Show help for a command",
 		Summary:     "Show help",
+		builtin:     true,
 		Args: []Arg{
 			{Name: "path", Description: "Command path, e.g. project build", Optional: true, Variadic: true},
 		},
 		Handler: func(ctx context.Context) error {
-			path := Args(ctx)
-			if len(path) == 0 {
-				return c.printHelp(c.Root)
+			chain := []*Command{c.Root}
+			for _, name := range Args(ctx) {
+				cur := chain[len(chain)-1]
+				next := findSubcommand(cur, name)
+				if next == nil {
+					return c.unknownCommandError(cur, chain[:len(chain)-1], name)
+				}
+				chain = append(chain, next)
 			}
-			target, ok := c.FindCommand(path...)
-			if !ok {
-				return fmt.Errorf("unknown command: %s", strings.Join(path, " "))
-			}
-			return c.printHelp(target)
+			return c.printHelp(chain[len(chain)-1], chain[:len(chain)-1])
 		},
 	}
 
-	_ = c.RegisterCommand(nil, help)
-}
-
-func (c *CLI) printHelp(cmd *Command) error {
-	if cmd == nil {
-		return nil
-	}
-
-	w := c.out
-	minSpacing := 8
-
-	if cmd.Summary != "" {
-		fmt.Fprintf(w, "%s - %s\n\n", cmd.Name, cmd.Summary)
-	} else {
-		fmt.Fprintf(w, "%s\n\n", cmd.Name)
-	}
-
-	if cmd.Description != "" {
-		fmt.Fprintf(w, "%s\n\n", cmd.Description)
-	}
-
-	fmt.Fprintf(w, "Usage:\n  %s", cmd.Name)
-	if len(cmd.Args) > 0 {
-		for _, a := range cmd.Args {
-			token := a.Name
-			if a.Variadic {
-				token = token + "..."
-			}
-			if a.Optional {
-				fmt.Fprintf(w, " [%s]", token)
-			} else {
-				fmt.Fprintf(w, " <%s>", token)
-			}
-		}
-	}
-	fmt.Fprintln(w)
-	fmt.Fprintln(w)
-
-	if len(cmd.Args) > 0 {
-		maxArgLen := 0
-		for _, a := range cmd.Args {
-			if len(a.Name) > maxArgLen {
-				maxArgLen = len(a.Name)
-			}
-		}
-		fmt.Fprintln(w, "Arguments:")
-		for _, a := range cmd.Args {
-			suffix := ""
-			if a.Optional {
-				suffix += " (optional)"
-			}
-			if a.Variadic {
-				suffix += " (variadic)"
-			}
-			spacing := strings.Repeat(" ", minSpacing)
-			fmt.Fprintf(w, "  %-*s%s%s%s\n", maxArgLen, a.Name, spacing, a.Description, suffix)
-		}
-		fmt.Fprintln(w)
-	}
-
-	fs := flag.NewFlagSet(cmd.Name, flag.ContinueOnError)
-	fs.SetOutput(io.Discard)
-	_ = fs.Bool("h", false, "show help")
-	_ = fs.Bool("help", false, "show help")
-	if cmd.Flags != nil {
-		cmd.Flags(fs)
+	if c.RegisterCommand(nil, help) == nil {
+		c.helpCommand = help
 	}
-
-	type flagInfo struct {
-		name, usage, def string
-	}
-	var flags []flagInfo
-	fs.VisitAll(func(f *flag.Flag) {
-		if f.Name == "h" || f.Name == "help" {
-			return
-		}
-		flags = append(flags, flagInfo{f.Name, f.Usage, f.DefValue})
-	})
-	if len(flags) > 0 {
-		maxFlagLen := 0
-		for _, fl := range flags {
-			if len(fl.name) > maxFlagLen {
-				maxFlagLen = len(fl.name)
-			}
-		}
-		sort.Slice(flags, func(i, j int) bool {
-			return flags[i].name < flags[j].name
-		})
-		fmt.Fprintln(w, "Flags:")
-		for _, fl := range flags {
-			spacing := strings.Repeat(" ", minSpacing)
-			fmt.Fprintf(w, "  -%-*s%s%s (default %q)\n", maxFlagLen, fl.name, spacing, fl.usage, fl.def)
-		}
-		fmt.Fprintln(w)
-	}
-
-	var subs []*Command
-	for _, sub := range cmd.Commands {
-		if sub == nil || sub.Hidden {
-			continue
-		}
-		subs = append(subs, sub)
-	}
-	if len(subs) > 0 {
-		maxCmdLen := 0
-		for _, sub := range subs {
-			if len(sub.Name) > maxCmdLen {
-				maxCmdLen = len(sub.Name)
-			}
-		}
-		sort.Slice(subs, func(i, j int) bool {
-			if subs[i].Name == c.HelpCommandName {
-				return true
-			}
-			if subs[j].Name == c.HelpCommandName {
-				return false
-			}
-			return subs[i].Name < subs[j].Name
-		})
-		fmt.Fprintln(w, "Commands:")
-		for _, sub := range subs {
-			desc := sub.Summary
-			if desc == "" {
-				desc = sub.Description
-			}
-			if desc == "" {
-				desc = "-"
-			}
-			spacing := strings.Repeat(" ", minSpacing)
-			fmt.Fprintf(w, "  %-*s%s%s\n", maxCmdLen, sub.Name, spacing, desc)
-		}
-		fmt.Fprintln(w)
-	}
-
-	return nil
 }
 
-func snapshotFlags(fs *flag.FlagSet) map[string]any {
+func snapshotFlags(fs *flagSet) map[string]any {
 	out := map[string]any{}
 	if fs == nil {
 		return out
 	}
 
-	fs.VisitAll(func(f *flag.Flag) {
-		// Most stdlib flag values implement Get() any
-		type getter interface{ Get() any }
-		if g, ok := f.Value.(getter); ok {
-			out[f.Name] = g.Get()
-			return
+	for _, e := range fs.entries {
+		if e.builtin {
+			continue
+		}
+
+		// Most flag values implement Get() any
+		if g, ok := e.value.(flag.Getter); ok {
+			out[e.def.Name] = g.Get()
+			continue
 		}
 
 		// Fallback to string
-		out[f.Name] = f.Value.String()
-	})
+		out[e.def.Name] = e.value.String()
+	}
 
 	return out
 }
@@ -1,8 +1,23 @@
 package cli
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/netip"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
 	"testing"
+	"text/template"
+	"time"
 )
 
 func TestNew(t *testing.T) {
@@ -117,3 +132,2254 @@ func TestCollides(t *testing.T) {
 		t.Error("Should not detect collision with new name")
 	}
 }
+
+func TestDeclarativeFlags(t *testing.T) {
+	var got map[string]any
+	root := &Command{
+		Name: "app",
+		Commands: []*Command{
+			{
+				Name: "build",
+				FlagDefs: []Flag{
+					{Name: "output", Default: "build", Usage: "output directory"},
+					{Name: "verbose", Type: BoolFlag, Usage: "verbose output"},
+					{Name: "jobs", Type: IntFlag, Default: 4},
+					{Name: "timeout", Type: DurationFlag, Default: "1m"},
+				},
+				Flags: func(fs *flag.FlagSet) {
+					fs.String("legacy", "old", "callback flag")
+				},
+				Handler: func(ctx context.Context) error {
+					got = Flags(ctx)
+					return nil
+				},
+			},
+		},
+	}
+
+	c := New(root, WithWriters(&bytes.Buffer{}, &bytes.Buffer{}))
+	if err := c.Run([]string{"build", "-verbose", "-jobs", "8"}); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if got["output"] != "build" {
+		t.Errorf("Expected default output 'build', got %v", got["output"])
+	}
+	if got["verbose"] != true {
+		t.Errorf("Expected verbose true, got %v", got["verbose"])
+	}
+	if got["jobs"] != 8 {
+		t.Errorf("Expected jobs 8, got %v", got["jobs"])
+	}
+	if got["timeout"] != time.Minute {
+		t.Errorf("Expected timeout 1m, got %v", got["timeout"])
+	}
+	if got["legacy"] != "old" {
+		t.Errorf("Expected callback flag 'old', got %v", got["legacy"])
+	}
+
+	if err := c.Run([]string{"build", "-jobs", "many"}); err == nil {
+		t.Error("Expected error for invalid int flag")
+	}
+}
+
+func TestRequiredAndHiddenFlags(t *testing.T) {
+	out := &bytes.Buffer{}
+	root := &Command{
+		Name: "app",
+		FlagDefs: []Flag{
+			{Name: "token", Usage: "api token", Required: true},
+			{Name: "debug-internal", Type: BoolFlag, Hidden: true},
+		},
+		Handler: func(ctx context.Context) error { return nil },
+	}
+
+	c := New(root, WithWriters(out, &bytes.Buffer{}))
+	if err := c.Run([]string{"-debug-internal"}); err == nil {
+		t.Error("Expected error for missing required flag")
+	}
+	if err := c.Run([]string{"-token", "abc"}); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	if err := c.Run([]string{"-h"}); err != nil {
+		t.Fatalf("help failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "-token") || !strings.Contains(out.String(), "(required)") {
+		t.Errorf("Expected required token flag in help, got:\n%s", out.String())
+	}
+	if strings.Contains(out.String(), "debug-internal") {
+		t.Errorf("Hidden flag should not appear in help, got:\n%s", out.String())
+	}
+}
+
+func TestFlagSetParse(t *testing.T) {
+	newSet := func() *flagSet {
+		cmd := &Command{
+			Name: "test",
+			FlagDefs: []Flag{
+				{Name: "verbose", Short: "v", Type: BoolFlag},
+				{Name: "extract", Short: "x", Type: BoolFlag},
+				{Name: "file", Short: "f"},
+				{Name: "output", Short: "o"},
+				{Name: "cache", Type: BoolFlag, Default: true},
+			},
+		}
+		fs, _, err := newFlagSet(cmd, nil)
+		if err != nil {
+			t.Fatalf("newFlagSet failed: %v", err)
+		}
+		return fs
+	}
+
+	tests := []struct {
+		args  []string
+		want  map[string]any
+		rest  []string
+		isErr bool
+	}{
+		{args: []string{"--verbose"}, want: map[string]any{"verbose": true}},
+		{args: []string{"-xvf", "archive.tar"}, want: map[string]any{"extract": true, "verbose": true, "file": "archive.tar"}},
+		{args: []string{"-xvfarchive.tar"}, want: map[string]any{"file": "archive.tar"}},
+		{args: []string{"--output=dist"}, want: map[string]any{"output": "dist"}},
+		{args: []string{"--output", "dist"}, want: map[string]any{"output": "dist"}},
+		{args: []string{"-odist"}, want: map[string]any{"output": "dist"}},
+		{args: []string{"-o=dist"}, want: map[string]any{"output": "dist"}},
+		{args: []string{"-output", "dist"}, want: map[string]any{"output": "dist"}},
+		{args: []string{"--no-cache"}, want: map[string]any{"cache": false}},
+		{args: []string{"-v", "--", "--output", "x"}, want: map[string]any{"verbose": true}, rest: []string{"--output", "x"}},
+		{args: []string{"src", "-v"}, want: map[string]any{"verbose": false}, rest: []string{"src", "-v"}},
+		{args: []string{"-"}, rest: []string{"-"}},
+		{args: []string{"--unknown"}, isErr: true},
+		{args: []string{"-q"}, isErr: true},
+		{args: []string{"--output"}, isErr: true},
+		{args: []string{"--no-output"}, isErr: true},
+	}
+
+	for _, tt := range tests {
+		fs := newSet()
+		err := fs.Parse(tt.args)
+		if tt.isErr {
+			if err == nil {
+				t.Errorf("Parse(%v): expected error", tt.args)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Parse(%v): unexpected error %v", tt.args, err)
+			continue
+		}
+		got := snapshotFlags(fs)
+		for k, v := range tt.want {
+			if got[k] != v {
+				t.Errorf("Parse(%v): flag %s = %v, want %v", tt.args, k, got[k], v)
+			}
+		}
+		if strings.Join(fs.Args(), " ") != strings.Join(tt.rest, " ") {
+			t.Errorf("Parse(%v): args = %v, want %v", tt.args, fs.Args(), tt.rest)
+		}
+	}
+}
+
+func TestHelpRendersShortAndLongFlags(t *testing.T) {
+	out := &bytes.Buffer{}
+	root := &Command{
+		Name: "app",
+		FlagDefs: []Flag{
+			{Name: "output", Short: "o", Default: "build", Usage: "output directory"},
+			{Name: "force", Type: BoolFlag, Usage: "overwrite files"},
+		},
+	}
+
+	c := New(root, WithWriters(out, &bytes.Buffer{}))
+	if err := c.Run([]string{"--help"}); err != nil {
+		t.Fatalf("help failed: %v", err)
+	}
+
+	if !strings.Contains(out.String(), "-o, --output string") {
+		t.Errorf("Expected short and long output flag in help, got:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), "    --force") {
+		t.Errorf("Expected long-only force flag in help, got:\n%s", out.String())
+	}
+}
+
+func TestInterspersedFlags(t *testing.T) {
+	var gotArgs []string
+	var gotFlags map[string]any
+	newRoot := func(interspersed bool) *Command {
+		return &Command{
+			Name: "app",
+			Commands: []*Command{
+				{
+					Name:         "build",
+					Interspersed: interspersed,
+					Args:         []Arg{{Name: "src", Variadic: true}},
+					FlagDefs:     []Flag{{Name: "verbose", Short: "v", Type: BoolFlag}},
+					Handler: func(ctx context.Context) error {
+						gotArgs = Args(ctx)
+						gotFlags = Flags(ctx)
+						return nil
+					},
+				},
+			},
+		}
+	}
+
+	c := New(newRoot(false), WithWriters(&bytes.Buffer{}, &bytes.Buffer{}))
+	if err := c.Run([]string{"build", "src", "--verbose"}); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if gotFlags["verbose"] != false || len(gotArgs) != 2 {
+		t.Errorf("Expected --verbose to be positional by default, got args %v flags %v", gotArgs, gotFlags)
+	}
+
+	c = New(newRoot(true), WithWriters(&bytes.Buffer{}, &bytes.Buffer{}))
+	if err := c.Run([]string{"build", "src", "--verbose", "lib", "--", "-v"}); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if gotFlags["verbose"] != true {
+		t.Error("Expected --verbose to be parsed after a positional argument")
+	}
+	if strings.Join(gotArgs, " ") != "src lib -v" {
+		t.Errorf("Expected args [src lib -v], got %v", gotArgs)
+	}
+
+	c = New(newRoot(false), WithWriters(&bytes.Buffer{}, &bytes.Buffer{}), WithInterspersed(true))
+	if err := c.Run([]string{"build", "src", "-v"}); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if gotFlags["verbose"] != true || strings.Join(gotArgs, " ") != "src" {
+		t.Errorf("Expected CLI-wide interspersed parsing, got args %v flags %v", gotArgs, gotFlags)
+	}
+}
+
+func TestPersistentFlags(t *testing.T) {
+	var got map[string]any
+	out := &bytes.Buffer{}
+	root := &Command{
+		Name: "app",
+		PersistentFlags: []Flag{
+			{Name: "config", Short: "c", Default: "app.json", Usage: "config file"},
+			{Name: "verbose", Short: "v", Type: BoolFlag, Usage: "verbose output"},
+		},
+		Commands: []*Command{
+			{
+				Name: "db",
+				Commands: []*Command{
+					{
+						Name:     "migrate",
+						FlagDefs: []Flag{{Name: "steps", Type: IntFlag, Default: 1, Usage: "steps to run"}},
+						Handler: func(ctx context.Context) error {
+							got = Flags(ctx)
+							return nil
+						},
+					},
+				},
+			},
+		},
+	}
+
+	c := New(root, WithWriters(out, &bytes.Buffer{}))
+
+	if err := c.Run([]string{"db", "migrate", "--config", "prod.json", "-v", "--steps", "3"}); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if got["config"] != "prod.json" || got["verbose"] != true || got["steps"] != 3 {
+		t.Errorf("Unexpected flags: %v", got)
+	}
+
+	if err := c.Run([]string{"-c", "dev.json", "db", "--verbose", "migrate"}); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if got["config"] != "dev.json" || got["verbose"] != true {
+		t.Errorf("Expected persistent flags before subcommands to apply, got %v", got)
+	}
+
+	if err := c.Run([]string{"db", "migrate"}); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if got["config"] != "app.json" || got["verbose"] != false {
+		t.Errorf("Expected persistent flag defaults, got %v", got)
+	}
+
+	out.Reset()
+	if err := c.Run([]string{"db", "migrate", "--help"}); err != nil {
+		t.Fatalf("help failed: %v", err)
+	}
+	help := out.String()
+	flagsAt := strings.Index(help, "Flags:")
+	globalAt := strings.Index(help, "Global Flags:")
+	if flagsAt < 0 || globalAt < 0 {
+		t.Fatalf("Expected Flags and Global Flags sections, got:\n%s", help)
+	}
+	if !strings.Contains(help[globalAt:], "--config") || strings.Contains(help[:globalAt], "--config") {
+		t.Errorf("Expected --config only under Global Flags, got:\n%s", help)
+	}
+	if !strings.Contains(help[flagsAt:globalAt], "--steps") {
+		t.Errorf("Expected --steps under Flags, got:\n%s", help)
+	}
+}
+
+func TestRequiredPersistentFlagSkipsBuiltins(t *testing.T) {
+	root := &Command{
+		Name:            "app",
+		PersistentFlags: []Flag{{Name: "token", Required: true}},
+		Commands: []*Command{
+			{Name: "run", Handler: func(ctx context.Context) error { return nil }},
+		},
+	}
+	dir := t.TempDir()
+	c := New(root, WithWriters(&bytes.Buffer{}, &bytes.Buffer{}), WithCompletionCommand(),
+		WithConfigPaths(filepath.Join(dir, "config.json")))
+
+	for _, args := range [][]string{
+		{"help", "run"},
+		{"completion", "bash"},
+		{specCommandName},
+		{"config", "show"},
+	} {
+		if err := c.Run(args); err != nil {
+			t.Errorf("%v: unexpected error: %v", args, err)
+		}
+	}
+
+	var usage *UsageError
+	if err := c.Run([]string{"run"}); !errors.As(err, &usage) {
+		t.Errorf("Expected run to require --token, got %v", err)
+	}
+}
+
+func TestRequiredFlagSkipsGroupCommands(t *testing.T) {
+	root := &Command{
+		Name:            "app",
+		PersistentFlags: []Flag{{Name: "token", Required: true}},
+		Commands: []*Command{{
+			Name:    "db",
+			Summary: "Database commands",
+			Commands: []*Command{
+				{Name: "migrate", Handler: func(ctx context.Context) error { return nil }},
+			},
+		}},
+	}
+	out := &bytes.Buffer{}
+	c := New(root, WithWriters(out, &bytes.Buffer{}))
+
+	if err := c.Run([]string{"db"}); err != nil {
+		t.Fatalf("Expected db to print help, got %v", err)
+	}
+	if !strings.Contains(out.String(), "db - Database commands") {
+		t.Errorf("Expected help for db, got:\n%s", out.String())
+	}
+
+	var usage *UsageError
+	if err := c.Run([]string{"db", "migrate"}); !errors.As(err, &usage) {
+		t.Errorf("Expected migrate to require --token, got %v", err)
+	}
+}
+
+func TestRequiredFlagFromEnv(t *testing.T) {
+	root := &Command{
+		Name: "app",
+		Commands: []*Command{{
+			Name:     "deploy",
+			FlagDefs: []Flag{{Name: "token", Env: []string{"TOKEN"}, Required: true}},
+			Handler:  func(ctx context.Context) error { return nil },
+		}},
+	}
+	c := New(root, WithWriters(&bytes.Buffer{}, &bytes.Buffer{}))
+
+	var usage *UsageError
+	if err := c.Run([]string{"deploy"}); !errors.As(err, &usage) {
+		t.Errorf("Expected a missing --token to be a usage error, got %v", err)
+	}
+
+	t.Setenv("TOKEN", "abc")
+	if err := c.Run([]string{"deploy"}); err != nil {
+		t.Errorf("Expected $TOKEN to satisfy the required flag, got %v", err)
+	}
+}
+
+func TestFlagEnvBinding(t *testing.T) {
+	var got map[string]any
+	var sources map[string]ValueSource
+	out := &bytes.Buffer{}
+	root := &Command{
+		Name: "app",
+		FlagDefs: []Flag{
+			{Name: "output", Default: "build", Usage: "output directory"},
+			{Name: "token", Env: []string{"APP_TOKEN", "GITHUB_TOKEN"}, Usage: "api token"},
+			{Name: "dry-run", Type: BoolFlag},
+		},
+		Handler: func(ctx context.Context) error {
+			got = Flags(ctx)
+			sources = map[string]ValueSource{}
+			for _, name := range []string{"output", "token", "dry-run"} {
+				sources[name] = FlagSource(ctx, name)
+			}
+			return nil
+		},
+	}
+
+	t.Setenv("MYAPP_OUTPUT", "from-env")
+	t.Setenv("GITHUB_TOKEN", "gh")
+	t.Setenv("MYAPP_DRY_RUN", "true")
+
+	c := New(root, WithWriters(out, &bytes.Buffer{}), WithEnvPrefix("MYAPP"), WithHelpWidth(120))
+	if err := c.Run([]string{"--output", "from-flag"}); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if got["output"] != "from-flag" || sources["output"] != SourceFlag {
+		t.Errorf("Expected command line to win over env, got %v (%s)", got["output"], sources["output"])
+	}
+	if got["token"] != "gh" || sources["token"] != SourceEnv {
+		t.Errorf("Expected token from $GITHUB_TOKEN, got %v (%s)", got["token"], sources["token"])
+	}
+	if got["dry-run"] != true || sources["dry-run"] != SourceEnv {
+		t.Errorf("Expected dry-run from $MYAPP_DRY_RUN, got %v (%s)", got["dry-run"], sources["dry-run"])
+	}
+
+	t.Setenv("APP_TOKEN", "app")
+	os.Unsetenv("MYAPP_OUTPUT")
+	if err := c.Run(nil); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if err := c.Run([]string{"--dry-run=false"}); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if got["output"] != "build" || sources["output"] != SourceDefault {
+		t.Errorf("Expected default output, got %v (%s)", got["output"], sources["output"])
+	}
+	if got["token"] != "app" {
+		t.Errorf("Expected first declared env var to win, got %v", got["token"])
+	}
+
+	t.Setenv("MYAPP_DRY_RUN", "maybe")
+	if err := c.Run([]string{"--output", "x"}); err == nil || !strings.Contains(err.Error(), "$MYAPP_DRY_RUN") {
+		t.Errorf("Expected error naming the env var, got %v", err)
+	}
+
+	out.Reset()
+	if err := c.Run([]string{"--help"}); err != nil {
+		t.Fatalf("help failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "[$APP_TOKEN, $GITHUB_TOKEN, $MYAPP_TOKEN]") {
+		t.Errorf("Expected bound env vars in help, got:\n%s", out.String())
+	}
+}
+
+func TestRequiredFlagFromConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	root := &Command{
+		Name:            "app",
+		PersistentFlags: []Flag{{Name: "token", Required: true}},
+		Commands: []*Command{
+			{Name: "deploy", Handler: func(ctx context.Context) error { return nil }},
+		},
+	}
+	c := New(root, WithWriters(&bytes.Buffer{}, &bytes.Buffer{}), WithConfigPaths(path))
+
+	var usage *UsageError
+	if err := c.Run([]string{"deploy"}); !errors.As(err, &usage) {
+		t.Errorf("Expected a missing --token to be a usage error, got %v", err)
+	}
+
+	os.WriteFile(path, []byte(`{"token": "abc"}`), 0o644)
+	if err := c.Run([]string{"deploy"}); err != nil {
+		t.Errorf("Expected the config value to satisfy the required flag, got %v", err)
+	}
+}
+
+func TestConfigFiles(t *testing.T) {
+	dir := t.TempDir()
+	global := filepath.Join(dir, "global.json")
+	explicit := filepath.Join(dir, "explicit.json")
+	kv := filepath.Join(dir, "local.kv")
+	os.WriteFile(global, []byte(`{"verbose": true, "build": {"output": "dist", "jobs": 2, "verbose": false}}`), 0o644)
+	os.WriteFile(explicit, []byte(`{"build.jobs": 8}`), 0o644)
+	os.WriteFile(kv, []byte("build.tag=v1"), 0o644)
+
+	// A toy decoder standing in for TOML or YAML.
+	decodeKV := func(data []byte, v any) error {
+		m := map[string]any{}
+		for _, line := range strings.Split(string(data), "\n") {
+			if k, val, ok := strings.Cut(line, "="); ok {
+				m[k] = val
+			}
+		}
+		*(v.(*map[string]any)) = m
+		return nil
+	}
+
+	var got map[string]any
+	var sources map[string]ValueSource
+	out := &bytes.Buffer{}
+	root := &Command{
+		Name:            "app",
+		PersistentFlags: []Flag{{Name: "verbose", Type: BoolFlag}},
+		Commands: []*Command{
+			{
+				Name: "build",
+				FlagDefs: []Flag{
+					{Name: "output", Default: "build"},
+					{Name: "jobs", Type: IntFlag, Default: 1},
+					{Name: "tag"},
+				},
+				Handler: func(ctx context.Context) error {
+					got = Flags(ctx)
+					sources = map[string]ValueSource{}
+					for name := range got {
+						sources[name] = FlagSource(ctx, name)
+					}
+					return nil
+				},
+			},
+		},
+	}
+
+	c := New(root,
+		WithWriters(out, &bytes.Buffer{}),
+		WithEnvPrefix("APP"),
+		WithConfigPaths(filepath.Join(dir, "missing.json"), global, kv),
+		WithConfigFlag("config"),
+		WithConfigDecoder("kv", decodeKV),
+	)
+
+	if err := c.Run([]string{"build"}); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if got["output"] != "dist" || got["jobs"] != 2 || got["tag"] != "v1" || sources["output"] != SourceConfig {
+		t.Errorf("Expected values from config files, got %v %v", got, sources)
+	}
+	if got["verbose"] != false {
+		t.Errorf("Expected build.verbose to override verbose for build, got %v", got["verbose"])
+	}
+
+	t.Setenv("APP_OUTPUT", "env")
+	if err := c.Run([]string{"--config", explicit, "build", "--tag", "v2"}); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if got["jobs"] != 8 {
+		t.Errorf("Expected explicit config to override global config, got jobs %v", got["jobs"])
+	}
+	if got["output"] != "env" || sources["output"] != SourceEnv {
+		t.Errorf("Expected env to override config, got %v (%s)", got["output"], sources["output"])
+	}
+	if got["tag"] != "v2" || sources["tag"] != SourceFlag {
+		t.Errorf("Expected flag to override config, got %v (%s)", got["tag"], sources["tag"])
+	}
+
+	if err := c.Run([]string{"build", "--config", filepath.Join(dir, "nope.json")}); err == nil {
+		t.Error("Expected error for missing explicit config file")
+	}
+
+	out.Reset()
+	if err := c.Run([]string{"config", "show"}); err != nil {
+		t.Fatalf("config show failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "missing.json (not found)") || !strings.Contains(out.String(), "build.output = \"dist\"") {
+		t.Errorf("Unexpected config show output:\n%s", out.String())
+	}
+}
+
+func TestCompletionCommand(t *testing.T) {
+	root := &Command{
+		Name: "app",
+		Commands: []*Command{
+			{
+				Name:     "build",
+				Aliases:  []string{"b"},
+				Summary:  "Build the project",
+				Args:     []Arg{{Name: "src"}},
+				FlagDefs: []Flag{{Name: "output", Short: "o", Usage: "output directory"}, {Name: "secret", Hidden: true}},
+			},
+			{Name: "internal", Hidden: true},
+		},
+	}
+
+	out := &bytes.Buffer{}
+	c := New(root, WithWriters(out, &bytes.Buffer{}), WithCompletionCommand())
+
+	for _, shell := range []string{"bash", "zsh", "fish", "powershell"} {
+		out.Reset()
+		if err := c.Run([]string{"completion", shell}); err != nil {
+			t.Fatalf("completion %s failed: %v", shell, err)
+		}
+		script := out.String()
+		for _, want := range []string{"build", "'app,b'", "output", "completion"} {
+			if !strings.Contains(script, want) {
+				t.Errorf("%s script missing %q:\n%s", shell, want, script)
+			}
+		}
+		if strings.Contains(script, "internal") || strings.Contains(script, "secret") {
+			t.Errorf("%s script should not include hidden commands or flags:\n%s", shell, script)
+		}
+	}
+
+	out.Reset()
+	if err := c.Run([]string{"completion", "zsh"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "<src> - Build the project") {
+		t.Errorf("Expected argument placeholder in zsh descriptions:\n%s", out.String())
+	}
+
+	if err := c.Run([]string{"completion", "tcsh"}); err == nil {
+		t.Error("Expected error for unsupported shell")
+	}
+
+	if _, ok := New(&Command{Name: "app"}).FindCommand("completion"); ok {
+		t.Error("completion command should only be installed when requested")
+	}
+}
+
+func TestDynamicCompletion(t *testing.T) {
+	root := &Command{
+		Name: "app",
+		Commands: []*Command{
+			{
+				Name: "checkout",
+				Args: []Arg{{
+					Name: "branch",
+					Complete: func(ctx context.Context, prefix string) []Completion {
+						remote, _ := Flags(ctx)["remote"].(string)
+						return []Completion{
+							{Value: remote + "/main", Description: "default branch"},
+							{Value: remote + "/dev"},
+							{Directive: CompleteNoFile},
+						}
+					},
+				}},
+				FlagDefs: []Flag{
+					{Name: "remote", Short: "r", Default: "origin"},
+					{Name: "env", Complete: func(ctx context.Context, prefix string) []Completion {
+						return []Completion{{Value: "prod"}, {Value: "staging"}}
+					}},
+					{Name: "out", Complete: func(ctx context.Context, prefix string) []Completion {
+						return []Completion{{Directive: CompleteDirs}}
+					}},
+				},
+			},
+		},
+	}
+
+	out := &bytes.Buffer{}
+	c := New(root, WithWriters(out, &bytes.Buffer{}), WithCompletionCommand())
+	hookRan := false
+	c.Hook(BeforeRun, func(ctx context.Context) error {
+		hookRan = true
+		return nil
+	})
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"ch"}, "checkout\n:2\n"},
+		{[]string{"checkout", ""}, "origin/main\tdefault branch\norigin/dev\n:2\n"},
+		{[]string{"checkout", "-r", "upstream", "upstream/d"}, "upstream/dev\n:2\n"},
+		{[]string{"checkout", "--env", "s"}, "staging\n:0\n"},
+		{[]string{"checkout", "--env=p"}, "--env=prod\n:0\n"},
+		{[]string{"checkout", "--out", ""}, ":8\n"},
+		{[]string{"checkout", "--remote", ""}, ":0\n"},
+		{[]string{"checkout", "--e"}, "--env\n:2\n"},
+		{[]string{"checkout", "main", ""}, ":2\n"},
+	}
+
+	for _, tt := range tests {
+		out.Reset()
+		if err := c.Run(append([]string{"__complete"}, tt.args...)); err != nil {
+			t.Fatalf("__complete %v failed: %v", tt.args, err)
+		}
+		if out.String() != tt.want {
+			t.Errorf("__complete %q = %q, want %q", tt.args, out.String(), tt.want)
+		}
+	}
+
+	if hookRan {
+		t.Error("Hooks should not run for completion requests")
+	}
+
+	out.Reset()
+	if err := c.Run([]string{"completion", "bash"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "__complete") || !strings.Contains(out.String(), "--env|--out) __app_dynamic") {
+		t.Errorf("Expected bash script to call back for dynamic completion:\n%s", out.String())
+	}
+}
+
+func TestTypedArgs(t *testing.T) {
+	var ctxGot context.Context
+	root := &Command{
+		Name: "app",
+		Commands: []*Command{
+			{
+				Name: "deploy",
+				Args: []Arg{
+					{Name: "env", Choices: []string{"staging", "prod"}},
+					{Name: "replicas", Type: IntFlag, Validate: func(v any) error {
+						if v.(int) < 1 {
+							return errors.New("must be at least 1")
+						}
+						return nil
+					}},
+					{Name: "timeout", Type: DurationFlag, Optional: true, Default: "30s"},
+					{Name: "version", Optional: true, Parser: func(s string) (any, error) {
+						return strings.TrimPrefix(s, "v"), nil
+					}},
+					{Name: "files", Type: PathFlag, Variadic: true},
+				},
+				Handler: func(ctx context.Context) error {
+					ctxGot = ctx
+					return nil
+				},
+			},
+		},
+	}
+
+	out := &bytes.Buffer{}
+	c := New(root, WithWriters(out, &bytes.Buffer{}))
+
+	if err := c.Run([]string{"deploy", "prod", "3"}); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if ArgString(ctxGot, "env") != "prod" || ArgInt(ctxGot, "replicas") != 3 {
+		t.Errorf("Unexpected args: env=%v replicas=%v", ArgValue(ctxGot, "env"), ArgValue(ctxGot, "replicas"))
+	}
+	if ArgDuration(ctxGot, "timeout") != 30*time.Second {
+		t.Errorf("Expected default timeout 30s, got %v", ArgValue(ctxGot, "timeout"))
+	}
+	if ArgValue(ctxGot, "version") != nil {
+		t.Errorf("Expected missing optional arg without default to be nil, got %v", ArgValue(ctxGot, "version"))
+	}
+
+	if err := c.Run([]string{"deploy", "staging", "2", "1m", "v1.2.0", "a/../b", "c/"}); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if ArgString(ctxGot, "version") != "1.2.0" || ArgDuration(ctxGot, "timeout") != time.Minute {
+		t.Errorf("Unexpected args: %v %v", ArgValue(ctxGot, "version"), ArgValue(ctxGot, "timeout"))
+	}
+	files, _ := ArgValue(ctxGot, "files").([]any)
+	if len(files) != 2 || files[0] != "b" || files[1] != "c" {
+		t.Errorf("Expected cleaned paths [b c], got %v", files)
+	}
+
+	for _, tt := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"deploy", "dev", "1"}, "argument <env>"},
+		{[]string{"deploy", "prod", "many"}, "argument <replicas>"},
+		{[]string{"deploy", "prod", "0"}, "must be at least 1"},
+		{[]string{"deploy", "prod", "1", "soon"}, "argument <timeout>"},
+	} {
+		err := c.Run(tt.args)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Run(%v): expected error containing %q, got %v", tt.args, tt.want, err)
+		}
+	}
+
+	out.Reset()
+	if err := c.Run([]string{"deploy", "--help"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "(one of: staging, prod)") || !strings.Contains(out.String(), `(default "30s")`) {
+		t.Errorf("Expected choices and default in help, got:\n%s", out.String())
+	}
+}
+
+func TestRootHandlerUndeclaredArgs(t *testing.T) {
+	var got []string
+	root := &Command{
+		Name:    "app",
+		Handler: func(ctx context.Context) error { got = Args(ctx); return nil },
+	}
+	c := New(root, WithWriters(&bytes.Buffer{}, &bytes.Buffer{}))
+
+	if err := c.Run([]string{"file.txt"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, []string{"file.txt"}) {
+		t.Errorf("Expected [file.txt], got %v", got)
+	}
+}
+
+func TestSuggestions(t *testing.T) {
+	newCLI := func(opts ...Option) *CLI {
+		root := &Command{
+			Name: "app",
+			Commands: []*Command{
+				{
+					Name:    "deploy",
+					Aliases: []string{"ship"},
+					FlagDefs: []Flag{
+						{Name: "verbose", Type: BoolFlag},
+						{Name: "secret-token", Hidden: true},
+					},
+					Handler: func(ctx context.Context) error { return nil },
+				},
+				{Name: "status", Handler: func(ctx context.Context) error { return nil }},
+				{Name: "debug-dump", Hidden: true, Handler: func(ctx context.Context) error { return nil }},
+			},
+		}
+		return New(root, append([]Option{WithWriters(&bytes.Buffer{}, &bytes.Buffer{})}, opts...)...)
+	}
+
+	for _, tt := range []struct {
+		args    []string
+		want    []string
+		notWant []string
+	}{
+		{[]string{"deplyo"}, []string{`unknown command "deplyo" for "app"`, "Did you mean this?", "\tdeploy"}, nil},
+		{[]string{"shpi"}, []string{"\tship"}, nil},
+		{[]string{"debug-dmp"}, []string{`unknown command "debug-dmp"`}, []string{"Did you mean", "debug-dump"}},
+		{[]string{"help", "stauts"}, []string{`unknown command "stauts" for "app"`, "\tstatus"}, nil},
+		{[]string{"deploy", "--verbos"}, []string{"unknown flag: --verbos", "\t--verbose"}, nil},
+		{[]string{"deploy", "--secret-tokn=x"}, []string{"unknown flag: --secret-tokn"}, []string{"Did you mean"}},
+		{[]string{"zzzzzz"}, []string{`unknown command "zzzzzz"`}, []string{"Did you mean"}},
+	} {
+		err := newCLI().Run(tt.args)
+		if err == nil {
+			t.Errorf("Run(%v): expected error", tt.args)
+			continue
+		}
+		for _, w := range tt.want {
+			if !strings.Contains(err.Error(), w) {
+				t.Errorf("Run(%v): expected %q in error, got %q", tt.args, w, err)
+			}
+		}
+		for _, w := range tt.notWant {
+			if strings.Contains(err.Error(), w) {
+				t.Errorf("Run(%v): did not expect %q in error, got %q", tt.args, w, err)
+			}
+		}
+	}
+
+	if err := newCLI(WithSuggestions(false)).Run([]string{"deplyo"}); err == nil || strings.Contains(err.Error(), "Did you mean") {
+		t.Errorf("Expected no suggestions when disabled, got %v", err)
+	}
+	if err := newCLI(WithSuggestionDistance(1)).Run([]string{"dploy"}); err == nil || !strings.Contains(err.Error(), "deploy") {
+		t.Errorf("Expected suggestion within distance 1, got %v", err)
+	}
+	if err := newCLI(WithSuggestionDistance(1)).Run([]string{"dpolyo"}); err == nil || strings.Contains(err.Error(), "Did you mean") {
+		t.Errorf("Expected no suggestion beyond distance 1, got %v", err)
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	for _, tt := range []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"kitten", "sitting", 3},
+		{"deploy", "deplyo", 2},
+		{"status", "status", 0},
+	} {
+		if got := levenshtein(tt.a, tt.b); got != tt.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestErrorTypesAndExitCodes(t *testing.T) {
+	root := &Command{
+		Name: "app",
+		Commands: []*Command{
+			{
+				Name: "db",
+				Commands: []*Command{
+					{
+						Name: "migrate",
+						Args: []Arg{{Name: "from"}, {Name: "to"}},
+						FlagDefs: []Flag{
+							{Name: "steps", Type: IntFlag},
+						},
+						Handler: func(ctx context.Context) error { return nil },
+					},
+				},
+			},
+			{
+				Name: "fail",
+				Handler: func(ctx context.Context) error {
+					return Exit(3, "deploy failed")
+				},
+			},
+			{
+				Name: "quiet",
+				Handler: func(ctx context.Context) error {
+					return Exit(4, "")
+				},
+			},
+			{
+				Name: "broken",
+				Handler: func(ctx context.Context) error {
+					return errors.New("boom")
+				},
+			},
+		},
+	}
+
+	stderr := &bytes.Buffer{}
+	c := New(root, WithWriters(&bytes.Buffer{}, stderr))
+
+	err := c.Run([]string{"db", "migrte"})
+	var usageErr *UsageError
+	var unknownErr *UnknownCommandError
+	if !errors.As(err, &usageErr) || !errors.As(err, &unknownErr) {
+		t.Fatalf("Expected UsageError wrapping UnknownCommandError, got %T: %v", err, err)
+	}
+	if usageErr.Command != "app db" || unknownErr.Name != "migrte" || len(unknownErr.Suggestions) != 1 {
+		t.Errorf("Unexpected error fields: %+v %+v", usageErr, unknownErr)
+	}
+
+	err = c.Run([]string{"db", "migrate", "1"})
+	var missingErr *MissingArgumentError
+	if !errors.As(err, &missingErr) || len(missingErr.Args) != 1 || missingErr.Args[0] != "to" {
+		t.Fatalf("Expected MissingArgumentError for <to>, got %v", err)
+	}
+	if err.Error() != "missing required arguments: <to>" {
+		t.Errorf("Unexpected message: %q", err)
+	}
+
+	for _, tt := range []struct {
+		args []string
+		code int
+	}{
+		{[]string{"db", "migrate", "1", "2"}, 0},
+		{[]string{"db", "migrate", "--steps", "x", "1", "2"}, ExitUsage},
+		{[]string{"db", "migrate", "--nope", "1", "2"}, ExitUsage},
+		{[]string{"db", "migrate", "1", "2", "3"}, ExitUsage},
+		{[]string{"fail"}, 3},
+		{[]string{"quiet"}, 4},
+		{[]string{"broken"}, 1},
+	} {
+		if code := ExitCode(c.Run(tt.args)); code != tt.code {
+			t.Errorf("ExitCode(Run(%v)) = %d, want %d", tt.args, code, tt.code)
+		}
+	}
+
+	var code int
+	c.exit = func(n int) { code = n }
+
+	for _, tt := range []struct {
+		args   []string
+		code   int
+		stderr string
+	}{
+		{[]string{"db", "migrate", "1"}, ExitUsage, "Error: missing required arguments: <to>\nRun 'app db migrate --help' for usage.\n"},
+		{[]string{"fail"}, 3, "Error: deploy failed\n"},
+		{[]string{"quiet"}, 4, ""},
+		{[]string{"broken"}, 1, "Error: boom\n"},
+		{[]string{"db", "migrate", "1", "2"}, 0, ""},
+	} {
+		stderr.Reset()
+		code = -1
+		c.RunAndExit(tt.args)
+		if code != tt.code || stderr.String() != tt.stderr {
+			t.Errorf("RunAndExit(%v): got code %d and stderr %q, want %d and %q", tt.args, code, stderr.String(), tt.code, tt.stderr)
+		}
+	}
+}
+
+func TestRunContext(t *testing.T) {
+	type key struct{}
+	var got any
+	root := &Command{
+		Name: "app",
+		Commands: []*Command{
+			{
+				Name: "wait",
+				Handler: func(ctx context.Context) error {
+					got = ctx.Value(key{})
+					if AppFromContext(ctx) == nil {
+						t.Error("Expected app in context")
+					}
+					<-ctx.Done()
+					return ctx.Err()
+				},
+			},
+		},
+	}
+	c := New(root, WithWriters(&bytes.Buffer{}, &bytes.Buffer{}))
+
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), key{}, "v"))
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	if err := c.RunContext(ctx, []string{"wait"}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if got != "v" {
+		t.Errorf("Expected handler to see the caller's context values, got %v", got)
+	}
+}
+
+func TestSignalHandling(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("signals cannot be sent to the current process on windows")
+	}
+	interrupt := func() {
+		p, err := os.FindProcess(os.Getpid())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := p.Signal(os.Interrupt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("cancels and runs after hooks", func(t *testing.T) {
+		started := make(chan struct{})
+		var hookErr error
+		root := &Command{
+			Name: "app",
+			Commands: []*Command{
+				{
+					Name: "serve",
+					Handler: func(ctx context.Context) error {
+						close(started)
+						<-ctx.Done()
+						return ctx.Err()
+					},
+					After: []Hook{func(ctx context.Context) error {
+						hookErr = ctx.Err()
+						if _, ok := ctx.Deadline(); !ok {
+							t.Error("Expected after hook context to carry the grace deadline")
+						}
+						return nil
+					}},
+				},
+			},
+		}
+		c := New(root, WithWriters(&bytes.Buffer{}, &bytes.Buffer{}), WithSignalHandling(time.Second))
+		c.exit = func(code int) { t.Errorf("Unexpected forced exit with %d", code) }
+
+		go func() {
+			<-started
+			interrupt()
+		}()
+		err := c.Run([]string{"serve"})
+
+		var exitErr *ExitError
+		if !errors.As(err, &exitErr) || exitErr.Code != 130 || exitErr.Err != nil {
+			t.Errorf("Expected silent ExitError with code 130, got %v", err)
+		}
+		if hookErr != nil {
+			t.Errorf("Expected after hook to get a live context, got %v", hookErr)
+		}
+	})
+
+	t.Run("second signal forces exit", func(t *testing.T) {
+		started := make(chan struct{})
+		exited := make(chan int, 1)
+		root := &Command{
+			Name: "app",
+			Commands: []*Command{
+				{
+					Name: "stuck",
+					Handler: func(ctx context.Context) error {
+						close(started)
+						<-ctx.Done()
+						interrupt()
+						select {
+						case <-exited:
+						case <-time.After(5 * time.Second):
+							t.Error("Expected second signal to force an exit")
+						}
+						return nil
+					},
+				},
+			},
+		}
+		c := New(root, WithWriters(&bytes.Buffer{}, &bytes.Buffer{}), WithSignalHandling(time.Minute))
+		var code int
+		c.exit = func(n int) {
+			code = n
+			exited <- n
+		}
+
+		go func() {
+			<-started
+			interrupt()
+		}()
+		_ = c.Run([]string{"stuck"})
+		if code != 130 {
+			t.Errorf("Expected forced exit with 130, got %d", code)
+		}
+	})
+}
+
+func TestHelpRendering(t *testing.T) {
+	t.Setenv("COLUMNS", "")
+	root := &Command{
+		Name:        "app",
+		Description: "An application whose description is long enough that it has to be wrapped onto more than one line of help output.",
+		FlagDefs: []Flag{
+			{Name: "output", Short: "o", Default: "dist", Usage: "directory the build artifacts are written to, created when missing"},
+		},
+		Commands: []*Command{
+			{Name: "build", Summary: "Build it", Handler: func(ctx context.Context) error { return nil }},
+		},
+	}
+
+	out := &bytes.Buffer{}
+	if err := New(root, WithWriters(out, &bytes.Buffer{}), WithHelpWidth(50)).Run([]string{"--help"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(out.String(), "\n") {
+		if len(line) > 50 {
+			t.Errorf("Line longer than 50 columns: %q", line)
+		}
+	}
+	if !strings.Contains(out.String(), "  -o, --output string    directory the build\n") {
+		t.Errorf("Expected aligned, wrapped flag description, got:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), "\n"+strings.Repeat(" ", 25)+"artifacts are written") {
+		t.Errorf("Expected continuation lines indented to the description column, got:\n%s", out.String())
+	}
+
+	out.Reset()
+	tmpl := `{{upper .Name}} usage: {{join .Usage ", "}}{{range .Commands}} [{{.Name}}]{{end}}`
+	c := New(root, WithWriters(out, &bytes.Buffer{}), WithHelpTemplate(tmpl, template.FuncMap{"upper": strings.ToUpper}))
+	if err := c.Run([]string{"--help"}); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "APP usage: app [command] [help] [build]" {
+		t.Errorf("Unexpected templated help: %q", out.String())
+	}
+
+	if err := New(root, WithWriters(out, &bytes.Buffer{}), WithHelpTemplate("{{.Nope", nil)).Run([]string{"--help"}); err == nil {
+		t.Error("Expected an error for a malformed help template")
+	}
+
+	var data *HelpData
+	c = New(root, WithWriters(out, &bytes.Buffer{}), WithHelpRenderer(HelpRendererFunc(func(w io.Writer, d *HelpData) error {
+		data = d
+		return nil
+	})))
+	if err := c.Run([]string{"build", "--help"}); err != nil {
+		t.Fatal(err)
+	}
+	if data == nil || data.Command.Name != "build" || len(data.Parents) != 1 || data.Width != 80 {
+		t.Errorf("Unexpected help data: %+v", data)
+	}
+}
+
+func TestWrapText(t *testing.T) {
+	got := wrapText("one two three four\n\n  indented words here", 2, 14)
+	want := []string{"  one two", "  three four", "", "    indented", "    words here"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("wrapText = %q, want %q", got, want)
+	}
+}
+
+func TestHelpShowsCommandPath(t *testing.T) {
+	t.Setenv("COLUMNS", "")
+	root := &Command{
+		Name: "myapp",
+		Commands: []*Command{
+			{
+				Name: "db",
+				Commands: []*Command{
+					{
+						Name:     "migrate",
+						Aliases:  []string{"mig", "m"},
+						Args:     []Arg{{Name: "version", Optional: true}},
+						FlagDefs: []Flag{{Name: "dry-run", Type: BoolFlag}},
+						Handler:  func(ctx context.Context) error { return nil },
+					},
+				},
+			},
+		},
+	}
+
+	out := &bytes.Buffer{}
+	c := New(root, WithWriters(out, &bytes.Buffer{}))
+
+	if err := c.Run([]string{"db", "migrate", "-h"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "Usage:\n  myapp db migrate [flags] [version]\n") {
+		t.Errorf("Expected full path in usage, got:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), "Aliases:\n  mig, m\n") {
+		t.Errorf("Expected aliases, got:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := c.Run([]string{"help", "db"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "Usage:\n  myapp db [command]\n\n") {
+		t.Errorf("Expected [command] usage, got:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), `Use "myapp help db [command]" for more information about a command.`) {
+		t.Errorf("Expected help footer, got:\n%s", out.String())
+	}
+
+	out.Reset()
+	c = New(root, WithWriters(out, &bytes.Buffer{}), WithHelpCommandName("db"))
+	if err := c.Run([]string{"db", "--help"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), `Use "myapp db [command] --help" for more information about a command.`) {
+		t.Errorf("Expected --help footer without a help command, got:\n%s", out.String())
+	}
+}
+
+func TestExamplesLongAndSeeAlso(t *testing.T) {
+	t.Setenv("COLUMNS", "")
+	migrate := &Command{
+		Name:        "migrate",
+		Description: "Run migrations",
+		Long:        "Run pending migrations.\n\n  Migrations are read from ./migrations.",
+		Args:        []Arg{{Name: "steps", Type: IntFlag, Optional: true}},
+		FlagDefs:    []Flag{{Name: "dry-run", Type: BoolFlag}},
+		Examples: []Example{
+			{Description: "Apply everything", Invocation: "myapp db migrate"},
+			{Invocation: "myapp db migrate --dry-run 2"},
+		},
+		SeeAlso: []string{"db seed", "myapp status", "db nope"},
+		Handler: func(ctx context.Context) error { return nil },
+	}
+	root := &Command{
+		Name: "myapp",
+		Commands: []*Command{
+			{
+				Name: "db",
+				Commands: []*Command{
+					migrate,
+					{Name: "seed", Summary: "Seed data", Handler: func(ctx context.Context) error { return nil }},
+				},
+			},
+			{Name: "status", Description: "Show status", Handler: func(ctx context.Context) error { return nil }},
+		},
+	}
+
+	out := &bytes.Buffer{}
+	c := New(root, WithWriters(out, &bytes.Buffer{}))
+	if err := c.Run([]string{"db", "migrate", "--help"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"Run pending migrations.\n\n  Migrations are read from ./migrations.\n",
+		"Examples:\n  # Apply everything\n  myapp db migrate\n\n  myapp db migrate --dry-run 2\n\n",
+		"See Also:\n  myapp db seed    Seed data\n  myapp status     Show status\n  myapp db nope    -\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected %q in help, got:\n%s", want, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "Run migrations") {
+		t.Errorf("Expected Long to replace Description, got:\n%s", out.String())
+	}
+
+	if err := c.VerifyExamples(); err != nil {
+		t.Errorf("Expected valid examples, got %v", err)
+	}
+
+	migrate.Examples = []Example{
+		{Invocation: "myapp db migrate --dry-rn"},
+		{Invocation: "myapp db migrate many"},
+		{Invocation: "myapp db seed"},
+		{Invocation: "other db migrate"},
+		{Invocation: `myapp db migrate "1`},
+	}
+	err := c.VerifyExamples()
+	if err == nil {
+		t.Fatal("Expected invalid examples to be reported")
+	}
+	for _, want := range []string{"unknown flag: --dry-rn", "argument <steps>", `runs "myapp db seed" instead`, `must start with "myapp"`, "unterminated"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected %q in %v", want, err)
+		}
+	}
+}
+
+func TestSplitCommandLine(t *testing.T) {
+	got, err := splitCommandLine(`app run 'a b' "c \"d\"" e\ f ""`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"app", "run", "a b", `c "d"`, "e f", ""}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("splitCommandLine = %q, want %q", got, want)
+	}
+}
+
+func TestCommandGroups(t *testing.T) {
+	t.Setenv("COLUMNS", "")
+	noop := func(ctx context.Context) error { return nil }
+	root := &Command{
+		Name: "app",
+		Groups: []CommandGroup{
+			{ID: "project", Title: "Project Commands"},
+			{ID: "db", Title: "Database Commands"},
+			{ID: "empty", Title: "Empty"},
+		},
+		Commands: []*Command{
+			{Name: "seed", Group: "db", Handler: noop},
+			{Name: "migrate", Group: "db", Order: -1, Handler: noop},
+			{Name: "build", Group: "project", Handler: noop},
+			{Name: "test", Group: "project", Handler: noop},
+			{Name: "version", Handler: noop},
+			{Name: "misc", Group: "unknown", Handler: noop},
+		},
+	}
+
+	out := &bytes.Buffer{}
+	if err := New(root, WithWriters(out, &bytes.Buffer{})).Run([]string{"--help"}); err != nil {
+		t.Fatal(err)
+	}
+
+	got := out.String()
+	want := "Project Commands:\n  build    -\n  test     -\n\n" +
+		"Database Commands:\n  migrate    -\n  seed       -\n\n" +
+		"Other Commands:\n  help       Show help\n  misc       -\n  version    -\n\n"
+	if !strings.Contains(got, want) {
+		t.Errorf("Expected grouped commands, got:\n%s", got)
+	}
+	if strings.Contains(got, "Empty:") {
+		t.Errorf("Expected empty groups to be omitted, got:\n%s", got)
+	}
+}
+
+func TestHiddenCommandsRun(t *testing.T) {
+	ran := false
+	root := &Command{
+		Name: "app",
+		Commands: []*Command{
+			{Name: "secret", Hidden: true, Handler: func(ctx context.Context) error {
+				ran = true
+				return nil
+			}},
+		},
+	}
+	out := &bytes.Buffer{}
+	c := New(root, WithWriters(out, &bytes.Buffer{}))
+
+	if err := c.Run([]string{"secret"}); err != nil || !ran {
+		t.Errorf("Expected hidden command to run, got ran=%v err=%v", ran, err)
+	}
+	if err := c.Run([]string{"--help"}); err != nil || strings.Contains(out.String(), "secret") {
+		t.Errorf("Expected hidden command to be left out of help, got:\n%s", out.String())
+	}
+}
+
+func TestSpec(t *testing.T) {
+	root := &Command{
+		Name:    "app",
+		Summary: "An app",
+		Groups:  []CommandGroup{{ID: "db", Title: "Database"}},
+		PersistentFlags: []Flag{
+			{Name: "verbose", Short: "v", Type: BoolFlag, Env: []string{"APP_VERBOSE"}},
+		},
+		Commands: []*Command{
+			{
+				Name:    "migrate",
+				Aliases: []string{"mig"},
+				Group:   "db",
+				Order:   1,
+				Args: []Arg{
+					{Name: "target", Choices: []string{"up", "down"}},
+					{Name: "steps", Type: IntFlag, Optional: true, Default: 1},
+				},
+				FlagDefs: []Flag{
+					{Name: "timeout", Default: 30 * time.Second, Usage: "lock timeout"},
+					{Name: "token", Required: true, Hidden: true},
+				},
+				Flags: func(fs *flag.FlagSet) {
+					fs.Int("retries", 3, "retry count")
+				},
+				Examples: []Example{{Description: "Up", Invocation: "app migrate up"}},
+				Handler:  func(ctx context.Context) error { return nil },
+			},
+		},
+	}
+
+	out := &bytes.Buffer{}
+	c := New(root, WithWriters(out, &bytes.Buffer{}))
+
+	spec := c.Spec()
+	if spec.Version != SpecVersion || spec.Command.Name != "app" || spec.Command.Runnable {
+		t.Errorf("Unexpected root spec: %+v", spec.Command)
+	}
+	if len(spec.Command.PersistentFlags) != 1 || spec.Command.PersistentFlags[0].Type != BoolFlag {
+		t.Errorf("Unexpected persistent flags: %+v", spec.Command.PersistentFlags)
+	}
+
+	var migrate CommandSpec
+	var sawSpec bool
+	for _, sub := range spec.Command.Commands {
+		switch sub.Name {
+		case "migrate":
+			migrate = sub
+		case specCommandName:
+			sawSpec = sub.Hidden
+		}
+	}
+	if !sawSpec {
+		t.Error("Expected the hidden __spec command in the spec")
+	}
+	if !migrate.Runnable || migrate.Group != "db" || migrate.Order != 1 || len(migrate.Aliases) != 1 || len(migrate.Examples) != 1 {
+		t.Errorf("Unexpected migrate spec: %+v", migrate)
+	}
+	if a := migrate.Args[1]; a.Type != IntFlag || a.Default != 1 || !a.Optional {
+		t.Errorf("Unexpected arg spec: %+v", a)
+	}
+	if len(migrate.Flags) != 3 {
+		t.Fatalf("Expected 3 flags, got %+v", migrate.Flags)
+	}
+	if f := migrate.Flags[0]; f.Type != DurationFlag || f.Default != "30s" {
+		t.Errorf("Unexpected timeout flag: %+v", f)
+	}
+	if f := migrate.Flags[1]; !f.Required || !f.Hidden {
+		t.Errorf("Unexpected token flag: %+v", f)
+	}
+	if f := migrate.Flags[2]; f.Name != "retries" || f.Type != IntFlag || f.Default != "3" {
+		t.Errorf("Unexpected stdlib flag: %+v", f)
+	}
+
+	if err := c.Run([]string{specCommandName}); err != nil {
+		t.Fatal(err)
+	}
+	var decoded Spec
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatalf("__spec did not print JSON: %v\n%s", err, out.String())
+	}
+	if decoded.Version != SpecVersion || len(decoded.Command.Commands) != len(spec.Command.Commands) {
+		t.Errorf("Unexpected decoded spec: %+v", decoded)
+	}
+
+	out.Reset()
+	if err := c.Run([]string{specCommandName, "--schema"}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out.Bytes(), SpecSchema()) {
+		t.Error("Expected __spec --schema to print the schema")
+	}
+}
+
+// TestSpecSchemaMatchesTypes keeps the published schema in step with the
+// json tags of the spec types.
+func TestSpecSchemaMatchesTypes(t *testing.T) {
+	var schema struct {
+		Properties map[string]any `json:"properties"`
+		Defs       map[string]struct {
+			Properties map[string]any `json:"properties"`
+		} `json:"$defs"`
+	}
+	if err := json.Unmarshal(SpecSchema(), &schema); err != nil {
+		t.Fatalf("Invalid schema: %v", err)
+	}
+
+	check := func(name string, props map[string]any, typ reflect.Type) {
+		fields := map[string]bool{}
+		for i := 0; i < typ.NumField(); i++ {
+			tag, _, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ",")
+			fields[tag] = true
+			if _, ok := props[tag]; !ok {
+				t.Errorf("%s: field %q missing from schema", name, tag)
+			}
+		}
+		for prop := range props {
+			if !fields[prop] {
+				t.Errorf("%s: schema property %q has no field", name, prop)
+			}
+		}
+	}
+
+	check("spec", schema.Properties, reflect.TypeOf(Spec{}))
+	check("command", schema.Defs["command"].Properties, reflect.TypeOf(CommandSpec{}))
+	check("group", schema.Defs["group"].Properties, reflect.TypeOf(GroupSpec{}))
+	check("arg", schema.Defs["arg"].Properties, reflect.TypeOf(ArgSpec{}))
+	check("flag", schema.Defs["flag"].Properties, reflect.TypeOf(FlagSpec{}))
+	check("flag_group", schema.Defs["flag_group"].Properties, reflect.TypeOf(FlagGroupSpec{}))
+	check("deprecated_alias", schema.Defs["deprecated_alias"].Properties, reflect.TypeOf(DeprecatedAliasSpec{}))
+	check("example", schema.Defs["example"].Properties, reflect.TypeOf(ExampleSpec{}))
+}
+
+func TestFromSpecRoundTrip(t *testing.T) {
+	root := &Command{
+		Name:   "app",
+		Groups: []CommandGroup{{ID: "db", Title: "Database"}},
+		PersistentFlags: []Flag{
+			{Name: "verbose", Short: "v", Type: BoolFlag},
+		},
+		Commands: []*Command{
+			{
+				Name:              "migrate",
+				Aliases:           []string{"mig"},
+				DeprecatedAliases: []DeprecatedAlias{{Name: "up", Message: `use "migrate"`, RemoveIn: "2.0"}},
+				Group:             "db",
+				Args: []Arg{
+					{Name: "target", Choices: []string{"up", "down"}},
+					{Name: "steps", Type: IntFlag, Optional: true, Default: 1000000},
+				},
+				FlagDefs: []Flag{
+					{Name: "timeout", Default: 30 * time.Second},
+					{Name: "wait", Default: time.Second, Deprecated: "use --timeout"},
+				},
+				FlagGroups: []FlagGroup{{Kind: FlagsExclusive, Flags: []string{"timeout", "retries"}, If: "verbose"}},
+				Flags: func(fs *flag.FlagSet) {
+					fs.Int("retries", 3, "retry count")
+				},
+				Handler: func(ctx context.Context) error { return nil },
+			},
+		},
+	}
+	want, err := json.Marshal(New(root, WithCompletionCommand()).Spec())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []any
+	c, err := FromSpec(bytes.NewReader(want), map[string]Handler{
+		"app migrate": func(ctx context.Context) error {
+			got = []any{ArgValue(ctx, "target"), ArgValue(ctx, "steps"), Flags(ctx)["timeout"], Flags(ctx)["retries"]}
+			return nil
+		},
+	}, WithCompletionCommand(), WithWriters(&bytes.Buffer{}, &bytes.Buffer{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	again, err := json.Marshal(c.Spec())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(want, again) {
+		t.Errorf("Spec did not round-trip:\nwant %s\ngot  %s", want, again)
+	}
+
+	if err := c.Run([]string{"mig", "up"}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, []any{"up", 1000000, 30 * time.Second, 3}) {
+		t.Errorf("Unexpected values: %#v", got)
+	}
+}
+
+func TestFromSpecErrors(t *testing.T) {
+	spec := `{"version": "1", "command": {"name": "app", "commands": [
+		{"name": "run", "runnable": true},
+		{"name": "help", "runnable": true, "builtin": true}
+	]}}`
+	noop := func(ctx context.Context) error { return nil }
+
+	_, err := FromSpec(strings.NewReader(spec), map[string]Handler{"app sync": noop})
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	for _, want := range []string{`command "app run" has no handler`, `handler "app sync" matches no command`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected %q in %q", want, err)
+		}
+	}
+
+	// Leaf commands need a handler even when the spec does not say runnable.
+	leaf := `{"command": {"name": "app", "commands": [{"name": "a", "commands": [{"name": "b"}]}]}}`
+	_, err = FromSpec(strings.NewReader(leaf), nil)
+	if err == nil {
+		t.Fatal("Expected an error for an unbound leaf command")
+	}
+	if want := `spec: command "app a b" has no handler`; err.Error() != want {
+		t.Errorf("Expected %q, got %q", want, err)
+	}
+	if _, err := FromSpec(strings.NewReader(leaf), map[string]Handler{"app a b": noop}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	if _, err := FromSpec(strings.NewReader(spec), map[string]Handler{"app run": noop}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	for _, bad := range []string{
+		`{"version": "2", "command": {"name": "app"}}`,
+		`{"version": "1", "command": {"name": "app", "handler": "x"}}`,
+		`{"version": "1", "command": {}}`,
+	} {
+		if _, err := FromSpec(strings.NewReader(bad), nil); err == nil {
+			t.Errorf("Expected an error for %s", bad)
+		}
+	}
+}
+
+func TestParseSpecDecoder(t *testing.T) {
+	// Stands in for a YAML decoder that produces interface-keyed maps.
+	decode := func(data []byte, v any) error {
+		*v.(*any) = map[any]any{
+			"command": map[any]any{
+				"name":     "app",
+				"commands": []any{map[any]any{"name": "run", "runnable": true}},
+			},
+		}
+		return nil
+	}
+
+	spec, err := ParseSpec(nil, decode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec.Command.Name != "app" || len(spec.Command.Commands) != 1 || !spec.Command.Commands[0].Runnable {
+		t.Errorf("Unexpected spec: %+v", spec)
+	}
+
+	ran := false
+	root, err := spec.Build(map[string]Handler{
+		"app run": func(ctx context.Context) error { ran = true; return nil },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := New(root, WithWriters(&bytes.Buffer{}, &bytes.Buffer{}))
+	if err := c.Run([]string{"run"}); err != nil || !ran {
+		t.Errorf("Expected run to be bound, got %v", err)
+	}
+}
+
+func TestBind(t *testing.T) {
+	type Common struct {
+		Verbose bool `flag:"verbose,v" usage:"verbose output"`
+	}
+	type Mode string
+	type buildOptions struct {
+		Common
+		Output  string        `flag:"output,o" env:"OUT" default:"build" usage:"output dir"`
+		Jobs    int           `flag:"jobs" default:"2"`
+		Timeout time.Duration `flag:"timeout" default:"1m"`
+		Mode    Mode          `flag:"mode" hidden:"true"`
+		Target  string        `arg:"target" choices:"linux,darwin" usage:"target OS"`
+		Files   []string      `arg:"files"`
+		ignored string
+	}
+
+	var got buildOptions
+	cmd, err := CommandFromStruct("build", func(ctx context.Context, in buildOptions) error {
+		got = in
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(cmd.FlagDefs) != 5 || len(cmd.Args) != 2 {
+		t.Fatalf("Unexpected definitions: %+v %+v", cmd.FlagDefs, cmd.Args)
+	}
+	if f := cmd.FlagDefs[1]; f.Name != "output" || f.Short != "o" || f.Env[0] != "OUT" || f.Type != StringFlag {
+		t.Errorf("Unexpected output flag: %+v", f)
+	}
+	if a := cmd.Args[1]; !a.Variadic || a.Type != StringFlag {
+		t.Errorf("Unexpected files arg: %+v", a)
+	}
+
+	c := New(&Command{Name: "app", Commands: []*Command{cmd}}, WithWriters(&bytes.Buffer{}, &bytes.Buffer{}))
+	t.Setenv("OUT", "dist")
+	if err := c.Run([]string{"build", "-v", "--jobs", "4", "--mode", "fast", "linux", "a.go", "b.go"}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := buildOptions{
+		Common:  Common{Verbose: true},
+		Output:  "dist",
+		Jobs:    4,
+		Timeout: time.Minute,
+		Mode:    "fast",
+		Target:  "linux",
+		Files:   []string{"a.go", "b.go"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+
+	if err := c.Run([]string{"build", "windows"}); err == nil {
+		t.Error("Expected an error for a value outside choices")
+	}
+}
+
+func TestBindErrors(t *testing.T) {
+
+	if _, err := CommandFromStruct("x", func(ctx context.Context, in int) error { return nil }); err == nil {
+		t.Error("Expected an error for a non-struct type")
+	}
+
+	type unsupported struct {
+		Ch chan int `flag:"ch"`
+	}
+	if _, err := CommandFromStruct("x", func(ctx context.Context, in unsupported) error { return nil }); err == nil || !strings.Contains(err.Error(), "unsupported type") {
+		t.Errorf("Expected an unsupported type error, got %v", err)
+	}
+
+	type badTag struct {
+		Force bool `flag:"force" required:"yes"`
+	}
+	if _, err := CommandFromStruct("x", func(ctx context.Context, in badTag) error { return nil }); err == nil {
+		t.Error("Expected an error for an invalid required tag")
+	}
+}
+
+func TestHandle(t *testing.T) {
+	type input struct {
+		Verbose bool     `flag:"verbose"`
+		Level   int      `flag:"level"`
+		Name    string   `arg:"name"`
+		Rest    []string `arg:"rest"`
+	}
+
+	var got input
+	root := &Command{
+		Name:            "app",
+		PersistentFlags: []Flag{{Name: "verbose", Type: BoolFlag}},
+		Commands: []*Command{
+			{
+				Name:     "greet",
+				FlagDefs: []Flag{{Name: "level", Default: 2}},
+				Args:     []Arg{{Name: "name"}, {Name: "rest", Variadic: true}},
+				Handler: Handle(func(ctx context.Context, in input) error {
+					got = in
+					return nil
+				}),
+			},
+			{
+				Name: "broken",
+				Handler: Handle(func(ctx context.Context, in input) error {
+					return nil
+				}),
+			},
+		},
+	}
+	c := New(root, WithWriters(&bytes.Buffer{}, &bytes.Buffer{}))
+
+	if err := c.Run([]string{"greet", "--verbose", "bob", "x", "y"}); err != nil {
+		t.Fatal(err)
+	}
+	want := input{Verbose: true, Level: 2, Name: "bob", Rest: []string{"x", "y"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+
+	err := c.Run([]string{"broken"})
+	if err == nil || err.Error() != "flag --level is not defined" {
+		t.Errorf("Expected an undefined flag error, got %v", err)
+	}
+}
+
+func TestFlagAsArgAs(t *testing.T) {
+	var checks []error
+	root := &Command{
+		Name:     "app",
+		FlagDefs: []Flag{{Name: "jobs", Default: 4}, {Name: "timeout", Default: time.Second}},
+		Args:     []Arg{{Name: "count", Type: IntFlag}, {Name: "files", Variadic: true}},
+		Handler: func(ctx context.Context) error {
+			jobs, err := FlagAs[int](ctx, "jobs")
+			if err != nil || jobs != 4 {
+				checks = append(checks, fmt.Errorf("jobs = %v, %v", jobs, err))
+			}
+			if d, err := FlagAs[time.Duration](ctx, "timeout"); err != nil || d != time.Second {
+				checks = append(checks, fmt.Errorf("timeout = %v, %v", d, err))
+			}
+			if _, err := FlagAs[string](ctx, "jobs"); err == nil || err.Error() != "flag --jobs: value is int, not string" {
+				checks = append(checks, fmt.Errorf("mismatch error = %v", err))
+			}
+			if _, err := FlagAs[int](ctx, "nope"); err == nil {
+				checks = append(checks, errors.New("expected an error for an unknown flag"))
+			}
+			if n, err := ArgAs[int](ctx, 0); err != nil || n != 3 {
+				checks = append(checks, fmt.Errorf("count = %v, %v", n, err))
+			}
+			if files, err := ArgAs[[]string](ctx, 1); err != nil || !reflect.DeepEqual(files, []string{"a", "b"}) {
+				checks = append(checks, fmt.Errorf("files = %v, %v", files, err))
+			}
+			if _, err := ArgAs[int](ctx, 2); err == nil {
+				checks = append(checks, errors.New("expected an error for an undeclared argument"))
+			}
+			return nil
+		},
+	}
+
+	if err := New(root, WithWriters(&bytes.Buffer{}, &bytes.Buffer{})).Run([]string{"3", "a", "b"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, err := range checks {
+		t.Error(err)
+	}
+}
+
+func TestFlagGroups(t *testing.T) {
+	root := &Command{
+		Name:            "app",
+		PersistentFlags: []Flag{{Name: "tls", Type: BoolFlag}},
+		Commands: []*Command{
+			{
+				Name: "login",
+				FlagDefs: []Flag{
+					{Name: "file"}, {Name: "stdin", Type: BoolFlag},
+					{Name: "user"}, {Name: "password"},
+					{Name: "cert"}, {Name: "key"},
+				},
+				FlagGroups: []FlagGroup{
+					{Kind: FlagsExclusive, Flags: []string{"file", "stdin"}},
+					{Kind: FlagsOneRequired, Flags: []string{"file", "stdin"}},
+					{Kind: FlagsRequiredTogether, Flags: []string{"user", "password"}},
+					{Kind: FlagsRequired, Flags: []string{"cert", "key"}, If: "tls"},
+				},
+				Handler: func(ctx context.Context) error { return nil },
+			},
+			{
+				Name:       "broken",
+				FlagGroups: []FlagGroup{{Kind: FlagsExclusive, Flags: []string{"nope"}}},
+				Handler:    func(ctx context.Context) error { return nil },
+			},
+		},
+	}
+	out := &bytes.Buffer{}
+	c := New(root, WithWriters(out, &bytes.Buffer{}), WithHelpWidth(100))
+
+	tests := []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"login", "--file", "f"}, ""},
+		{[]string{"login", "--stdin", "--user", "u", "--password", "p"}, ""},
+		{[]string{"login", "--file", "f", "--stdin"}, "flags --file and --stdin cannot be used together"},
+		{[]string{"login"}, "at least one of the flags --file, --stdin is required"},
+		{[]string{"login", "--stdin", "--user", "u"}, "flags --user, --password must be used together: missing --password"},
+		{[]string{"--tls", "login", "--stdin", "--cert", "c"}, "missing required flag: --key when --tls is set"},
+		{[]string{"--tls", "login", "--stdin", "--cert", "c", "--key", "k"}, ""},
+	}
+	for _, tt := range tests {
+		err := c.Run(tt.args)
+		if tt.err == "" {
+			if err != nil {
+				t.Errorf("%v: unexpected error: %v", tt.args, err)
+			}
+			continue
+		}
+		var usage *UsageError
+		if !errors.As(err, &usage) || usage.Err.Error() != tt.err {
+			t.Errorf("%v: expected usage error %q, got %v", tt.args, tt.err, err)
+		}
+	}
+
+	err := c.Run([]string{"broken"})
+	var usage *UsageError
+	if err == nil || errors.As(err, &usage) || !strings.Contains(err.Error(), "unknown flag --nope") {
+		t.Errorf("Expected a definition error, got %v", err)
+	}
+
+	if err := c.Run([]string{"login", "--help"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"Flag Rules:",
+		"  --file, --stdin       at most one may be set\n",
+		"  --user, --password    must be set together\n",
+		"  --cert, --key         required when --tls is set\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected %q in help:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestFlagGroupsFromEnv(t *testing.T) {
+	root := &Command{
+		Name: "app",
+		Commands: []*Command{{
+			Name: "login",
+			FlagDefs: []Flag{
+				{Name: "file"}, {Name: "stdin", Type: BoolFlag, Env: []string{"LOGIN_STDIN"}},
+				{Name: "user", Env: []string{"LOGIN_USER"}}, {Name: "password"},
+			},
+			FlagGroups: []FlagGroup{
+				{Kind: FlagsExclusive, Flags: []string{"file", "stdin"}},
+				{Kind: FlagsOneRequired, Flags: []string{"file", "stdin"}},
+				{Kind: FlagsRequiredTogether, Flags: []string{"user", "password"}},
+			},
+			Handler: func(ctx context.Context) error { return nil },
+		}},
+	}
+	c := New(root, WithWriters(&bytes.Buffer{}, &bytes.Buffer{}))
+
+	t.Setenv("LOGIN_STDIN", "true")
+	if err := c.Run([]string{"login"}); err != nil {
+		t.Errorf("Expected $LOGIN_STDIN to satisfy the one-required group, got %v", err)
+	}
+
+	var usage *UsageError
+	err := c.Run([]string{"login", "--file", "f"})
+	if want := "flags --file and --stdin cannot be used together"; !errors.As(err, &usage) || usage.Err.Error() != want {
+		t.Errorf("Expected usage error %q, got %v", want, err)
+	}
+
+	t.Setenv("LOGIN_USER", "u")
+	err = c.Run([]string{"login"})
+	if want := "flags --user, --password must be used together: missing --password"; !errors.As(err, &usage) || usage.Err.Error() != want {
+		t.Errorf("Expected usage error %q, got %v", want, err)
+	}
+}
+
+func TestTimeArgDefault(t *testing.T) {
+	since := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	var got any
+	root := &Command{
+		Name: "app",
+		Commands: []*Command{{
+			Name:    "log",
+			Args:    []Arg{{Name: "since", Description: "start time", Optional: true, Default: since}},
+			Handler: func(ctx context.Context) error { got = ArgValue(ctx, "since"); return nil },
+		}},
+	}
+	out := &bytes.Buffer{}
+	c := New(root, WithWriters(out, &bytes.Buffer{}), WithHelpWidth(100))
+
+	if err := c.Run([]string{"log"}); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := got.(time.Time); !ok || !v.Equal(since) {
+		t.Errorf("Expected %v, got %#v", since, got)
+	}
+
+	if err := c.Run([]string{"log", "--help"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := `start time (optional) (default "2024-01-02T00:00:00Z")`; !strings.Contains(out.String(), want) {
+		t.Errorf("Expected %q in help:\n%s", want, out.String())
+	}
+}
+
+func TestRichFlagTypes(t *testing.T) {
+	var got map[string]any
+	root := &Command{
+		Name: "app",
+		FlagDefs: []Flag{
+			{Name: "tag", Type: StringSliceFlag, Default: []string{"latest"}},
+			{Name: "port", Type: IntSliceFlag},
+			{Name: "label", Type: StringMapFlag},
+			{Name: "verbose", Short: "v", Type: CountFlag},
+			{Name: "format", Choices: []string{"json", "text"}, Default: "text"},
+			{Name: "limit", Type: ByteSizeFlag, Default: "1MiB"},
+			{Name: "since", Type: TimeFlag},
+			{Name: "endpoint", Type: URLFlag},
+			{Name: "bind", Type: IPFlag},
+		},
+		Handler: func(ctx context.Context) error {
+			got = Flags(ctx)
+			return nil
+		},
+	}
+	out := &bytes.Buffer{}
+	c := New(root, WithWriters(out, &bytes.Buffer{}), WithHelpWidth(120), WithCompletionCommand())
+
+	if err := c.Run([]string{"--format", "text"}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got["tag"], []string{"latest"}) || !reflect.DeepEqual(got["port"], []int{}) || got["limit"] != int64(1<<20) || got["verbose"] != 0 {
+		t.Errorf("Unexpected defaults: %v", got)
+	}
+
+	err := c.Run([]string{
+		"--tag", "a", "--tag", "b,c", "--port", "80,443",
+		"--label", "env=prod", "--label", "team=core,tier=1",
+		"-vvv", "--format", "json", "--limit", "1.5GB",
+		"--since", "2026-01-02", "--endpoint", "https://example.com/api", "--bind", "::1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	endpoint, _ := url.Parse("https://example.com/api")
+	want := map[string]any{
+		"tag":      []string{"a", "b", "c"},
+		"port":     []int{80, 443},
+		"label":    map[string]string{"env": "prod", "team": "core", "tier": "1"},
+		"verbose":  3,
+		"format":   "json",
+		"limit":    int64(1_500_000_000),
+		"since":    time.Date(2026, 1, 2, 0, 0, 0, 0, time.Local),
+		"endpoint": endpoint,
+		"bind":     netip.MustParseAddr("::1"),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+
+	for _, bad := range [][]string{
+		{"--format", "xml"},
+		{"--label", "novalue"},
+		{"--limit", "lots"},
+		{"--endpoint", "example.com"},
+		{"--bind", "300.1.1.1"},
+		{"--port", "http"},
+	} {
+		if err := c.Run(bad); err == nil {
+			t.Errorf("Expected an error for %v", bad)
+		}
+	}
+
+	out.Reset()
+	if err := c.Run([]string{"--help"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"--format json|text",
+		"--label key=value",
+		"--limit size",
+		`(default "1MiB")`,
+		"--tag strings",
+		"-v, --verbose ",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected %q in help:\n%s", want, out.String())
+		}
+	}
+
+	out.Reset()
+	if err := c.Run([]string{"__complete", "--format", "j"}); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != fmt.Sprintf("json\n:%d\n", CompleteNoFile) {
+		t.Errorf("Unexpected enum completion %q", out.String())
+	}
+}
+
+func TestByteSize(t *testing.T) {
+	for in, want := range map[string]int64{
+		"512": 512, "10KB": 10_000, "10kib": 10 << 10, "1.5 MiB": 3 << 19, "2GB": 2e9, "1TiB": 1 << 40,
+	} {
+		got, err := parseByteSize(in)
+		if err != nil || got != want {
+			t.Errorf("parseByteSize(%q) = %d, %v; want %d", in, got, err, want)
+		}
+		if back, _ := parseByteSize(formatByteSize(got)); back != got {
+			t.Errorf("formatByteSize(%d) = %q does not parse back", got, formatByteSize(got))
+		}
+	}
+	if _, err := parseByteSize("9EiB"); err == nil {
+		t.Error("Expected an out of range error")
+	}
+	for _, in := range []string{"-1", "-1KB", "-0.5MiB"} {
+		if _, err := parseByteSize(in); err == nil || !strings.Contains(err.Error(), "negative") {
+			t.Errorf("parseByteSize(%q): expected a negative size error, got %v", in, err)
+		}
+	}
+	for _, in := range []string{"NaN", "nanKB", "Inf", "-infMB", "1e3", "1.5E2KiB", "0x10", ".", "1..5MB", "+-1"} {
+		if n, err := parseByteSize(in); err == nil {
+			t.Errorf("parseByteSize(%q) = %d, expected an error", in, n)
+		}
+	}
+}
+
+func TestConfigMapAndSliceFlags(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.json")
+	if err := os.WriteFile(path, []byte(`{"label": {"env": "prod", "tier": 1}, "tag": ["a", "b"]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var got map[string]any
+	root := &Command{
+		Name: "app",
+		FlagDefs: []Flag{
+			{Name: "label", Type: StringMapFlag, Default: map[string]string{"owner": "me"}},
+			{Name: "tag", Type: StringSliceFlag},
+		},
+		Handler: func(ctx context.Context) error {
+			got = Flags(ctx)
+			return nil
+		},
+	}
+	c := New(root, WithWriters(&bytes.Buffer{}, &bytes.Buffer{}), WithConfigPaths(path))
+	if err := c.Run([]string{"--tag", "x"}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got["label"], map[string]string{"env": "prod", "tier": "1"}) || !reflect.DeepEqual(got["tag"], []string{"x"}) {
+		t.Errorf("Unexpected values: %v", got)
+	}
+}
+
+func TestBindRichTypes(t *testing.T) {
+	type options struct {
+		Tags    []string          `flag:"tag"`
+		Labels  map[string]string `flag:"label"`
+		Debug   int               `flag:"debug,d" type:"count"`
+		Limit   int64             `flag:"limit" type:"bytes" default:"1KiB"`
+		Format  string            `flag:"format" choices:"json,text" default:"text"`
+		Addr    netip.Addr        `flag:"addr"`
+		Started time.Time         `flag:"started"`
+	}
+
+	var got options
+	cmd, err := CommandFromStruct("run", func(ctx context.Context, in options) error {
+		got = in
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmd.FlagDefs[4].Type != EnumFlag {
+		t.Errorf("Expected an enum flag, got %+v", cmd.FlagDefs[4])
+	}
+
+	c := New(cmd, WithWriters(&bytes.Buffer{}, &bytes.Buffer{}))
+	if err := c.Run([]string{"--tag", "a,b", "--label", "k=v", "-dd", "--addr", "10.0.0.1", "--started", "2026-05-01"}); err != nil {
+		t.Fatal(err)
+	}
+	want := options{
+		Tags:    []string{"a", "b"},
+		Labels:  map[string]string{"k": "v"},
+		Debug:   2,
+		Limit:   1024,
+		Format:  "text",
+		Addr:    netip.MustParseAddr("10.0.0.1"),
+		Started: time.Date(2026, 5, 1, 0, 0, 0, 0, time.Local),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+}
+
+func TestDeprecation(t *testing.T) {
+	ran := 0
+	root := &Command{
+		Name: "app",
+		Commands: []*Command{
+			{
+				Name:              "remove",
+				Summary:           "Remove an item",
+				DeprecatedAliases: []DeprecatedAlias{{Name: "rm", Message: `use "remove"`, RemoveIn: "2.0"}},
+				FlagDefs: []Flag{
+					{Name: "output", Usage: "output format"},
+					{Name: "format", Usage: "output format", Deprecated: "use --output", RemoveIn: "1.5"},
+				},
+				Handler: func(ctx context.Context) error { ran++; return nil },
+			},
+			{
+				Name:       "purge",
+				Summary:    "Purge items",
+				Deprecated: `use "remove --all"`,
+				Handler:    func(ctx context.Context) error { ran++; return nil },
+			},
+		},
+	}
+	out, errOut := &bytes.Buffer{}, &bytes.Buffer{}
+	c := New(root, WithWriters(out, errOut), WithHelpWidth(100))
+
+	tests := []struct {
+		args []string
+		warn string
+	}{
+		{[]string{"remove", "--output", "json"}, ""},
+		{[]string{"rm"}, "Warning: alias \"rm\" of \"app remove\" is deprecated and will be removed in 2.0: use \"remove\"\n"},
+		{[]string{"remove", "--format", "json"}, "Warning: flag --format is deprecated and will be removed in 1.5: use --output\n"},
+		{[]string{"purge"}, "Warning: command \"app purge\" is deprecated: use \"remove --all\"\n"},
+	}
+	for _, tt := range tests {
+		errOut.Reset()
+		before := ran
+		if err := c.Run(tt.args); err != nil {
+			t.Fatalf("%v: unexpected error: %v", tt.args, err)
+		}
+		if ran != before+1 {
+			t.Errorf("%v: expected the handler to run", tt.args)
+		}
+		if errOut.String() != tt.warn {
+			t.Errorf("%v: expected warning %q, got %q", tt.args, tt.warn, errOut.String())
+		}
+	}
+
+	out.Reset()
+	if err := c.Run([]string{"--help"}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out.String(), "purge") {
+		t.Errorf("Expected default help to hide deprecated commands, got:\n%s", out.String())
+	}
+	out.Reset()
+	if err := c.Run([]string{"--help-all"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "  purge     Purge items (deprecated)\n") {
+		t.Errorf("Expected --help-all to list deprecated commands, got:\n%s", out.String())
+	}
+	out.Reset()
+	before := ran
+	if err := c.Run([]string{"remove", "--help-all=false"}); err != nil {
+		t.Fatal(err)
+	}
+	if ran != before+1 || out.Len() > 0 {
+		t.Errorf("Expected --help-all=false to run the command, got:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := c.Run([]string{"remove", "--help"}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out.String(), "--format") || strings.Contains(out.String(), "Aliases:") {
+		t.Errorf("Expected default help to hide deprecated flags and aliases, got:\n%s", out.String())
+	}
+	out.Reset()
+	if err := c.Run([]string{"remove", "--help-all"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"Aliases:\n  rm (deprecated)\n",
+		"--format string    output format (default \"\") (deprecated and will be removed in 1.5",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected --help-all to contain %q, got:\n%s", want, out.String())
+		}
+	}
+
+	out.Reset()
+	if err := c.Run([]string{"help", "purge"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "Deprecated: use \"remove --all\"\n") {
+		t.Errorf("Expected help for a deprecated command to say so, got:\n%s", out.String())
+	}
+
+	if err := c.VerifyDeprecations("1.4.9"); err != nil {
+		t.Errorf("Expected nothing due in 1.4.9, got %v", err)
+	}
+	err := c.VerifyDeprecations("v2.0.0")
+	if err == nil {
+		t.Fatal("Expected deprecations due in 2.0.0")
+	}
+	for _, want := range []string{
+		`alias "rm" of "app remove" was due for removal in 2.0`,
+		`flag --format of "app remove" was due for removal in 1.5`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected %q in %v", want, err)
+		}
+	}
+}
+
+func TestVersionReached(t *testing.T) {
+	tests := []struct {
+		current, target string
+		want            bool
+	}{
+		{"1.0", "1.0.0", true},
+		{"1.2.3", "1.10", false},
+		{"v2.0.0", "2.0", true},
+		{"2.0.0-rc.1", "2.0.0", false},
+		{"2.0.0", "2.0.0-rc.1", true},
+		{"2.0.0-rc.2", "2.0.0-rc.1", true},
+		{"2.0.1+build.5", "2.0.1", true},
+	}
+	for _, tt := range tests {
+		got, err := versionReached(tt.current, tt.target)
+		if err != nil || got != tt.want {
+			t.Errorf("versionReached(%q, %q) = %v, %v; want %v", tt.current, tt.target, got, err, tt.want)
+		}
+	}
+	if _, err := versionReached("next", "1.0"); err == nil {
+		t.Error("Expected an invalid version to fail")
+	}
+}
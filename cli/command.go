@@ -3,6 +3,7 @@ package cli
 import (
 	"flag"
 	"fmt"
+	"strings"
 )
 
 type Arg struct {
@@ -10,6 +11,35 @@ type Arg struct {
 	Description string
 	Optional    bool
 	Variadic    bool
+
+	// Type converts the raw argument using the same value types as flags.
+	// It is inferred from Default when unset and defaults to StringFlag.
+	Type FlagType
+	// Parser converts the raw argument instead of Type.
+	Parser func(s string) (any, error)
+	// Default is used for an optional argument that was not given.
+	Default any
+	// Choices restricts the raw argument to the listed values.
+	Choices []string
+	// Validate checks the converted value.
+	Validate func(v any) error
+
+	// Complete offers dynamic shell completion candidates for the argument.
+	Complete CompleteFunc
+}
+
+// Example is a sample invocation shown in help. Invocation is the full
+// command line, starting with the program name.
+type Example struct {
+	Description string
+	Invocation  string
+}
+
+// CommandGroup is a section of subcommands in help. Commands join it by
+// setting Group to its ID.
+type CommandGroup struct {
+	ID    string
+	Title string
 }
 
 type Command struct {
@@ -18,9 +48,44 @@ type Command struct {
 	Summary     string
 	Hidden      bool
 
-	Aliases []string
-	Args    []Arg
-	Flags   func(fs *flag.FlagSet)
+	// Deprecated marks the command as deprecated with a message telling
+	// users what to use instead. It still runs but warns, and is only
+	// listed in help with --help-all.
+	Deprecated string
+	// RemoveIn is the version a deprecated command or alias is going away
+	// in; see CLI.VerifyDeprecations.
+	RemoveIn string
+	// DeprecatedAliases are old names that keep working but warn.
+	DeprecatedAliases []DeprecatedAlias
+
+	// Long is an extended, markdown formatted description shown in the
+	// command's own help instead of Description.
+	Long string
+	// Examples are listed in help and can be checked with CLI.VerifyExamples.
+	Examples []Example
+	// SeeAlso lists related commands by path, e.g. "db seed".
+	SeeAlso []string
+
+	// Group places the command in one of its parent's Groups in help.
+	Group string
+	// Order sorts the command among its siblings in help; commands with the
+	// same Order are sorted by name.
+	Order int
+	// Groups are the sections subcommands are listed under, in order.
+	Groups []CommandGroup
+
+	Aliases  []string
+	Args     []Arg
+	FlagDefs []Flag
+	Flags    func(fs *flag.FlagSet)
+
+	// PersistentFlags are accepted by this command and every command below it.
+	PersistentFlags []Flag
+	// FlagGroups are checked after parsing, e.g. to make flags exclusive.
+	FlagGroups []FlagGroup
+
+	// Interspersed allows flags to follow positional arguments.
+	Interspersed bool
 
 	Handler  Handler
 	Commands []*Command
@@ -29,6 +94,9 @@ type Command struct {
 	After  []Hook
 
 	Middleware []Middleware
+
+	// builtin marks commands installed by the CLI itself.
+	builtin bool
 }
 
 func validatePositionalArgs(cmd *Command, parsed []string) error {
@@ -36,7 +104,7 @@ func validatePositionalArgs(cmd *Command, parsed []string) error {
 		return nil
 	}
 
-	required := 0
+	var required []string
 	var hasVariadic bool
 
 	for _, a := range cmd.Args {
@@ -44,12 +112,12 @@ func validatePositionalArgs(cmd *Command, parsed []string) error {
 			hasVariadic = true
 		}
 		if !a.Optional && !a.Variadic {
-			required++
+			required = append(required, a.Name)
 		}
 	}
 
-	if len(parsed) < required {
-		return fmt.Errorf("missing required arguments (need %d)", required)
+	if len(parsed) < len(required) {
+		return &MissingArgumentError{Args: required[len(parsed):]}
 	}
 
 	if !hasVariadic && len(parsed) > len(cmd.Args) {
@@ -59,13 +127,32 @@ func validatePositionalArgs(cmd *Command, parsed []string) error {
 	return nil
 }
 
+// argsUsage renders positional arguments for usage lines, e.g. "<src> [dest...]".
+func argsUsage(args []Arg) string {
+	tokens := make([]string, 0, len(args))
+	for _, a := range args {
+		token := a.Name
+		if a.Variadic {
+			token = token + "..."
+		}
+		if a.Optional {
+			tokens = append(tokens, "["+token+"]")
+		} else {
+			tokens = append(tokens, "<"+token+">")
+		}
+	}
+	return strings.Join(tokens, " ")
+}
+
+// findSubcommand resolves a name or alias among the subcommands of cmd.
+// Hidden commands are left out of help and completion but still run.
 func findSubcommand(cmd *Command, token string) *Command {
 	if cmd == nil {
 		return nil
 	}
 
 	for _, sub := range cmd.Commands {
-		if sub == nil || sub.Hidden {
+		if sub == nil {
 			continue
 		}
 		if sub.Name == token {
@@ -76,6 +163,9 @@ func findSubcommand(cmd *Command, token string) *Command {
 				return sub
 			}
 		}
+		if _, ok := deprecatedAlias(sub, token); ok {
+			return sub
+		}
 	}
 
 	return nil
@@ -97,6 +187,9 @@ func collides(parent *Command, nameOrAlias string) bool {
 				return true
 			}
 		}
+		if _, ok := deprecatedAlias(sub, nameOrAlias); ok {
+			return true
+		}
 	}
 	return false
 }
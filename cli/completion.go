@@ -0,0 +1,375 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+const completeCommandName = "__complete"
+
+var completionShells = []string{"bash", "zsh", "fish", "powershell"}
+
+// CompletionDirective tells the shell how to treat a set of candidates.
+// Directives are bit flags and may be combined.
+type CompletionDirective int
+
+const (
+	// CompleteDefault lets the shell fall back to file completion when
+	// there are no candidates.
+	CompleteDefault CompletionDirective = 0
+	// CompleteNoSpace keeps the shell from adding a space after the
+	// completed word.
+	CompleteNoSpace CompletionDirective = 1
+	// CompleteNoFile disables the fallback to file completion.
+	CompleteNoFile CompletionDirective = 2
+	// CompleteFiles asks the shell to complete file names.
+	CompleteFiles CompletionDirective = 4
+	// CompleteDirs asks the shell to complete directory names only.
+	CompleteDirs CompletionDirective = 8
+)
+
+// Completion is a single candidate returned by a Complete callback. A
+// Completion without a Value only contributes its Directive.
+type Completion struct {
+	Value       string
+	Description string
+	Directive   CompletionDirective
+}
+
+// CompleteFunc returns candidates for the word being completed. The context
+// carries the command, the positional arguments and the flags parsed so far.
+type CompleteFunc func(ctx context.Context, prefix string) []Completion
+
+// completionNode is a visible command flattened out of the command tree for
+// script generation.
+type completionNode struct {
+	id    string
+	cmd   *Command
+	subs  []*Command
+	flags []*flagEntry
+	// next maps every subcommand name and alias to the id of its node.
+	next map[string]string
+	keys []string
+	// dynamicArgs is set when a positional argument has a Complete callback.
+	dynamicArgs bool
+}
+
+var nonIdent = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+func completionID(path []string) string {
+	return nonIdent.ReplaceAllString(strings.Join(path, "_"), "_")
+}
+
+func (c *CLI) completionNodes() []*completionNode {
+	var nodes []*completionNode
+
+	var walk func(cmd *Command, parents []*Command, path []string)
+	walk = func(cmd *Command, parents []*Command, path []string) {
+		node := &completionNode{id: completionID(path), cmd: cmd, next: map[string]string{}}
+		for _, a := range cmd.Args {
+			if a.Complete != nil || len(a.Choices) > 0 {
+				node.dynamicArgs = true
+			}
+		}
+
+		if fs, _, err := c.newFlagSet(cmd, parents); err == nil {
+			for _, e := range fs.entries {
+				if e.def.listed() {
+					node.flags = append(node.flags, e)
+				}
+			}
+		}
+
+		for _, sub := range cmd.Commands {
+			if sub == nil || !sub.listed() {
+				continue
+			}
+			node.subs = append(node.subs, sub)
+			id := completionID(append(path[:len(path):len(path)], sub.Name))
+			for _, name := range append([]string{sub.Name}, sub.Aliases...) {
+				node.next[name] = id
+				node.keys = append(node.keys, name)
+			}
+		}
+		nodes = append(nodes, node)
+
+		chain := append(parents[:len(parents):len(parents)], cmd)
+		for _, sub := range node.subs {
+			walk(sub, chain, append(path[:len(path):len(path)], sub.Name))
+		}
+	}
+	walk(c.Root, nil, []string{c.Root.Name})
+
+	return nodes
+}
+
+// completesValue reports whether the flag's values are completed by the
+// program rather than the shell.
+func (e *flagEntry) completesValue() bool {
+	return e.def.Complete != nil || len(e.def.Choices) > 0
+}
+
+// flagTokens lists every spelling of the flag a shell should offer.
+func (e *flagEntry) flagTokens() []string {
+	var tokens []string
+	if len(e.def.Name) > 1 {
+		tokens = append(tokens, "--"+e.def.Name)
+	}
+	if e.def.Short != "" {
+		tokens = append(tokens, "-"+e.def.Short)
+	} else if len(e.def.Name) == 1 {
+		tokens = append(tokens, "-"+e.def.Name)
+	}
+	return tokens
+}
+
+func commandSummary(cmd *Command) string {
+	desc := cmd.Summary
+	if desc == "" {
+		desc = cmd.Description
+	}
+	if len(cmd.Args) > 0 {
+		if desc == "" {
+			return argsUsage(cmd.Args)
+		}
+		desc = argsUsage(cmd.Args) + " - " + desc
+	}
+	return desc
+}
+
+// GenerateCompletion writes a completion script for shell, one of bash, zsh,
+// fish or powershell, covering every visible command of the tree.
+func (c *CLI) GenerateCompletion(w io.Writer, shell string) error {
+	nodes := c.completionNodes()
+	name := c.Root.Name
+	fn := completionID([]string{name})
+
+	switch shell {
+	case "bash":
+		writeBashCompletion(w, name, fn, nodes)
+	case "zsh":
+		writeZshCompletion(w, name, fn, nodes)
+	case "fish":
+		writeFishCompletion(w, name, fn, nodes)
+	case "powershell":
+		writePowerShellCompletion(w, name, nodes)
+	default:
+		return fmt.Errorf("unsupported shell %q (supported: %s)", shell, strings.Join(completionShells, ", "))
+	}
+	return nil
+}
+
+func (c *CLI) installCompletionCommand() {
+	if !c.completionCommand || collides(c.Root, "completion") {
+		return
+	}
+
+	_ = c.RegisterCommand(nil, &Command{
+		Name:    "completion",
+		Summary: "Generate shell completion scripts",
+		builtin: true,
+		Description: "Generate a completion script for bash, zsh, fish or powershell.\n\n" +
+			"  bash:       source <(" + c.Root.Name + " completion bash)\n" +
+			"  zsh:        " + c.Root.Name + " completion zsh > \"${fpath[1]}/_" + c.Root.Name + "\"\n" +
+			"  fish:       " + c.Root.Name + " completion fish | source\n" +
+			"  powershell: " + c.Root.Name + " completion powershell | Out-String | Invoke-Expression",
+		Args: []Arg{
+			{Name: "shell", Description: "Shell to generate the script for", Choices: completionShells},
+		},
+		Handler: func(ctx context.Context) error {
+			return c.GenerateCompletion(c.out, Args(ctx)[0])
+		},
+	})
+
+	_ = c.RegisterCommand(nil, &Command{
+		Name:        completeCommandName,
+		Description: "Print completion candidates for the last argument; used by the completion scripts",
+		Hidden:      true,
+		builtin:     true,
+		Args: []Arg{
+			{Name: "args", Optional: true, Variadic: true},
+		},
+		Handler: func(ctx context.Context) error {
+			return c.runComplete(ctx, Args(ctx))
+		},
+	})
+}
+
+// runComplete implements the __complete protocol. args are the words of the
+// command line after the program name, the last one being the (possibly
+// empty) word under the cursor. It prints one candidate per line as
+// "value" or "value\tdescription", followed by ":<directive>".
+func (c *CLI) runComplete(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		args = []string{""}
+	}
+
+	completions, directive := c.complete(ctx, args[:len(args)-1], args[len(args)-1])
+
+	clean := strings.NewReplacer("\n", " ", "\r", " ", "\t", " ")
+	for _, comp := range completions {
+		if comp.Description != "" {
+			fmt.Fprintf(c.out, "%s\t%s\n", clean.Replace(comp.Value), clean.Replace(comp.Description))
+		} else {
+			fmt.Fprintln(c.out, clean.Replace(comp.Value))
+		}
+	}
+	fmt.Fprintf(c.out, ":%d\n", directive)
+	return nil
+}
+
+// complete resolves the candidates for toComplete given the preceding words.
+func (c *CLI) complete(ctx context.Context, words []string, toComplete string) ([]Completion, CompletionDirective) {
+	cmd, parents := c.Root, []*Command(nil)
+	for len(words) > 0 {
+		if sub := findSubcommand(cmd, words[0]); sub != nil && sub.Name != completeCommandName {
+			parents = append(parents, cmd)
+			cmd = sub
+			words = words[1:]
+			continue
+		}
+		n := persistentFlagSpan(cmd, parents, words)
+		if n == 0 {
+			break
+		}
+		words = words[n:]
+	}
+
+	fs, _, err := c.newFlagSet(cmd, parents)
+	if err != nil {
+		return nil, CompleteDefault
+	}
+
+	var pending *flagEntry
+	if len(words) > 0 {
+		if e, hasValue, ok := fs.resolveToken(words[len(words)-1]); ok && !hasValue && !e.isBool() {
+			pending = e
+			words = words[:len(words)-1]
+		}
+	}
+
+	// Parse what we have so callbacks can see earlier flags and arguments;
+	// errors are expected on partial command lines and ignored.
+	terminated := false
+	for _, w := range words {
+		if w == "--" {
+			terminated = true
+		}
+	}
+	_ = fs.Parse(words)
+	_ = fs.applyEnv()
+	positional := fs.Args()
+
+	ctx = context.WithValue(ctx, commandKey, cmd)
+	ctx = context.WithValue(ctx, argsKey, positional)
+	ctx = context.WithValue(ctx, flagsKey, snapshotFlags(fs))
+	ctx = context.WithValue(ctx, flagSourcesKey, snapshotSources(fs))
+
+	switch {
+	case pending != nil:
+		return completeFlagValue(ctx, pending, "", toComplete)
+	case !terminated && strings.HasPrefix(toComplete, "--") && strings.Contains(toComplete, "="):
+		name, prefix, _ := strings.Cut(toComplete[2:], "=")
+		e := fs.lookup(name)
+		if e == nil || e.isBool() {
+			return nil, CompleteNoFile
+		}
+		return completeFlagValue(ctx, e, "--"+name+"=", prefix)
+	case !terminated && strings.HasPrefix(toComplete, "-"):
+		var out []Completion
+		for _, e := range fs.entries {
+			if !e.def.listed() {
+				continue
+			}
+			for _, t := range e.flagTokens() {
+				if strings.HasPrefix(t, toComplete) {
+					out = append(out, Completion{Value: t, Description: e.def.Usage})
+				}
+			}
+		}
+		return out, CompleteNoFile
+	}
+
+	var out []Completion
+	directive := CompleteNoFile
+
+	if len(positional) == 0 && !terminated {
+		for _, sub := range cmd.Commands {
+			if sub == nil || !sub.listed() {
+				continue
+			}
+			for _, name := range append([]string{sub.Name}, sub.Aliases...) {
+				if strings.HasPrefix(name, toComplete) {
+					out = append(out, Completion{Value: name, Description: sub.Summary})
+				}
+			}
+		}
+	}
+
+	if arg, ok := argAt(cmd.Args, len(positional)); ok {
+		switch {
+		case arg.Complete != nil:
+			comps, d := filterCompletions(arg.Complete(ctx, toComplete), "", toComplete)
+			out = append(out, comps...)
+			directive = d
+		case len(arg.Choices) > 0:
+			for _, choice := range arg.Choices {
+				if strings.HasPrefix(choice, toComplete) {
+					out = append(out, Completion{Value: choice})
+				}
+			}
+		case arg.kind() == PathFlag:
+			directive = CompleteFiles
+		default:
+			directive = CompleteDefault
+		}
+	}
+
+	return out, directive
+}
+
+func argAt(args []Arg, i int) (Arg, bool) {
+	if len(args) == 0 {
+		return Arg{}, false
+	}
+	if i < len(args) {
+		return args[i], true
+	}
+	if last := args[len(args)-1]; last.Variadic {
+		return last, true
+	}
+	return Arg{}, false
+}
+
+func completeFlagValue(ctx context.Context, e *flagEntry, valuePrefix, toComplete string) ([]Completion, CompletionDirective) {
+	if e.def.Complete == nil {
+		if len(e.def.Choices) > 0 {
+			var choices []Completion
+			for _, choice := range e.def.Choices {
+				choices = append(choices, Completion{Value: choice, Directive: CompleteNoFile})
+			}
+			return filterCompletions(choices, valuePrefix, toComplete)
+		}
+		return nil, CompleteDefault
+	}
+	return filterCompletions(e.def.Complete(ctx, toComplete), valuePrefix, toComplete)
+}
+
+// filterCompletions drops candidates that do not start with toComplete,
+// prepends valuePrefix to the rest and merges their directives.
+func filterCompletions(in []Completion, valuePrefix, toComplete string) ([]Completion, CompletionDirective) {
+	var out []Completion
+	directive := CompleteDefault
+	for _, comp := range in {
+		directive |= comp.Directive
+		if comp.Value == "" || !strings.HasPrefix(comp.Value, toComplete) {
+			continue
+		}
+		comp.Value = valuePrefix + comp.Value
+		out = append(out, comp)
+	}
+	return out, directive
+}
@@ -0,0 +1,362 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// The scripts below resolve the command path and offer subcommands and flags
+// from tables generated out of the command tree. Positions backed by a
+// Complete callback call back into "<program> __complete <words...> <word>"
+// instead; see runComplete for the protocol.
+
+func shQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// splitValueFlags returns the spellings of every value-taking flag of n,
+// separated by whether the value has a Complete callback.
+func (n *completionNode) splitValueFlags() (static, dynamic []string) {
+	for _, e := range n.flags {
+		if e.isBool() {
+			continue
+		}
+		if e.completesValue() {
+			dynamic = append(dynamic, e.flagTokens()...)
+		} else {
+			static = append(static, e.flagTokens()...)
+		}
+	}
+	return static, dynamic
+}
+
+func writeBashCompletion(w io.Writer, name, fn string, nodes []*completionNode) {
+	fmt.Fprintf(w, "# bash completion for %s\n\n", name)
+	fmt.Fprintf(w, "__%s_dynamic() {\n", fn)
+	fmt.Fprintf(w, "    local line directive=0\n")
+	fmt.Fprintf(w, "    local -a values=()\n")
+	fmt.Fprintf(w, "    while IFS= read -r line; do\n")
+	fmt.Fprintf(w, "        if [[ \"${line}\" == :* ]]; then\n")
+	fmt.Fprintf(w, "            directive=\"${line#:}\"\n")
+	fmt.Fprintf(w, "        elif [[ -n \"${line}\" ]]; then\n")
+	fmt.Fprintf(w, "            values+=(\"${line%%%%$'\\t'*}\")\n")
+	fmt.Fprintf(w, "        fi\n")
+	fmt.Fprintf(w, "    done < <(\"${COMP_WORDS[0]}\" %s \"${COMP_WORDS[@]:1:COMP_CWORD-1}\" \"${cur}\" 2>/dev/null)\n\n", completeCommandName)
+	fmt.Fprintf(w, "    if (( directive & %d )); then\n", CompleteDirs)
+	fmt.Fprintf(w, "        COMPREPLY=($(compgen -d -- \"${cur}\"))\n")
+	fmt.Fprintf(w, "    elif (( directive & %d )); then\n", CompleteFiles)
+	fmt.Fprintf(w, "        COMPREPLY=($(compgen -f -- \"${cur}\"))\n")
+	fmt.Fprintf(w, "    else\n")
+	fmt.Fprintf(w, "        COMPREPLY=(\"${values[@]}\")\n")
+	fmt.Fprintf(w, "        (( directive & %d )) && compopt -o nospace\n", CompleteNoSpace)
+	fmt.Fprintf(w, "        (( directive & %d )) && compopt +o default\n", CompleteNoFile)
+	fmt.Fprintf(w, "    fi\n")
+	fmt.Fprintf(w, "    return 0\n")
+	fmt.Fprintf(w, "}\n\n")
+	fmt.Fprintf(w, "_%s_completions() {\n", fn)
+	fmt.Fprintf(w, "    local cur prev word cmd i commands flags\n")
+	fmt.Fprintf(w, "    cur=\"${COMP_WORDS[COMP_CWORD]}\"\n")
+	fmt.Fprintf(w, "    prev=\"${COMP_WORDS[COMP_CWORD-1]}\"\n")
+	fmt.Fprintf(w, "    cmd=%s\n\n", nodes[0].id)
+	fmt.Fprintf(w, "    for ((i = 1; i < COMP_CWORD; i++)); do\n")
+	fmt.Fprintf(w, "        word=\"${COMP_WORDS[i]}\"\n")
+	fmt.Fprintf(w, "        case \"${cmd},${word}\" in\n")
+	for _, n := range nodes {
+		for _, k := range n.keys {
+			fmt.Fprintf(w, "            %s) cmd=%s ;;\n", shQuote(n.id+","+k), n.next[k])
+		}
+	}
+	fmt.Fprintf(w, "        esac\n")
+	fmt.Fprintf(w, "    done\n\n")
+	fmt.Fprintf(w, "    case \"${cmd}\" in\n")
+	for _, n := range nodes {
+		var flags []string
+		for _, e := range n.flags {
+			flags = append(flags, e.flagTokens()...)
+		}
+		staticFlags, dynamicFlags := n.splitValueFlags()
+		fmt.Fprintf(w, "        %s)\n", n.id)
+		if len(staticFlags) > 0 || len(dynamicFlags) > 0 {
+			fmt.Fprintf(w, "            case \"${prev}\" in\n")
+			if len(dynamicFlags) > 0 {
+				fmt.Fprintf(w, "                %s) __%s_dynamic; return ;;\n", strings.Join(dynamicFlags, "|"), fn)
+			}
+			if len(staticFlags) > 0 {
+				fmt.Fprintf(w, "                %s) return ;;\n", strings.Join(staticFlags, "|"))
+			}
+			fmt.Fprintf(w, "            esac\n")
+		}
+		if n.dynamicArgs {
+			fmt.Fprintf(w, "            [[ \"${cur}\" != -* ]] && { __%s_dynamic; return; }\n", fn)
+		}
+		fmt.Fprintf(w, "            commands=%s\n", shQuote(strings.Join(n.keys, " ")))
+		fmt.Fprintf(w, "            flags=%s\n", shQuote(strings.Join(flags, " ")))
+		fmt.Fprintf(w, "            ;;\n")
+	}
+	fmt.Fprintf(w, "    esac\n\n")
+	fmt.Fprintf(w, "    if [[ \"${cur}\" == -* ]]; then\n")
+	fmt.Fprintf(w, "        COMPREPLY=($(compgen -W \"${flags}\" -- \"${cur}\"))\n")
+	fmt.Fprintf(w, "    else\n")
+	fmt.Fprintf(w, "        COMPREPLY=($(compgen -W \"${commands}\" -- \"${cur}\"))\n")
+	fmt.Fprintf(w, "    fi\n")
+	fmt.Fprintf(w, "}\n\n")
+	fmt.Fprintf(w, "complete -o default -F _%s_completions %s\n", fn, name)
+}
+
+// zshDescribe escapes an item for _describe, which splits on the first
+// unescaped colon.
+func zshDescribe(name, desc string) string {
+	name = strings.ReplaceAll(name, ":", `\:`)
+	if desc == "" {
+		return shQuote(name)
+	}
+	return shQuote(name + ":" + strings.ReplaceAll(desc, "\n", " "))
+}
+
+func writeZshCompletion(w io.Writer, name, fn string, nodes []*completionNode) {
+	fmt.Fprintf(w, "#compdef %s\n\n", name)
+	fmt.Fprintf(w, "__%s_dynamic() {\n", fn)
+	fmt.Fprintf(w, "    local line directive=0\n")
+	fmt.Fprintf(w, "    local -a values\n")
+	fmt.Fprintf(w, "    for line in \"${(@f)$(${words[1]} %s \"${(@)words[2,CURRENT-1]}\" \"${words[CURRENT]}\" 2>/dev/null)}\"; do\n", completeCommandName)
+	fmt.Fprintf(w, "        if [[ $line == :* ]]; then\n")
+	fmt.Fprintf(w, "            directive=${line#:}\n")
+	fmt.Fprintf(w, "        elif [[ $line == *$'\\t'* ]]; then\n")
+	fmt.Fprintf(w, "            values+=(\"${${line%%%%$'\\t'*}//:/\\\\:}:${line#*$'\\t'}\")\n")
+	fmt.Fprintf(w, "        elif [[ -n $line ]]; then\n")
+	fmt.Fprintf(w, "            values+=(\"${line//:/\\\\:}\")\n")
+	fmt.Fprintf(w, "        fi\n")
+	fmt.Fprintf(w, "    done\n\n")
+	fmt.Fprintf(w, "    if (( directive & %d )); then\n", CompleteDirs)
+	fmt.Fprintf(w, "        _files -/\n")
+	fmt.Fprintf(w, "    elif (( directive & %d )); then\n", CompleteFiles)
+	fmt.Fprintf(w, "        _files\n")
+	fmt.Fprintf(w, "    elif (( directive & %d )); then\n", CompleteNoSpace)
+	fmt.Fprintf(w, "        _describe -t values 'values' values -S ''\n")
+	fmt.Fprintf(w, "    else\n")
+	fmt.Fprintf(w, "        _describe -t values 'values' values\n")
+	fmt.Fprintf(w, "    fi\n")
+	fmt.Fprintf(w, "    (( ${#values} == 0 && !(directive & %d) )) && _files\n", CompleteNoFile|CompleteFiles|CompleteDirs)
+	fmt.Fprintf(w, "    return 0\n")
+	fmt.Fprintf(w, "}\n\n")
+	fmt.Fprintf(w, "_%s() {\n", fn)
+	fmt.Fprintf(w, "    local cmd=%s word i\n", nodes[0].id)
+	fmt.Fprintf(w, "    local -a commands flags\n\n")
+	fmt.Fprintf(w, "    for ((i = 2; i < CURRENT; i++)); do\n")
+	fmt.Fprintf(w, "        word=${words[i]}\n")
+	fmt.Fprintf(w, "        case \"${cmd},${word}\" in\n")
+	for _, n := range nodes {
+		for _, k := range n.keys {
+			fmt.Fprintf(w, "            %s) cmd=%s ;;\n", shQuote(n.id+","+k), n.next[k])
+		}
+	}
+	fmt.Fprintf(w, "        esac\n")
+	fmt.Fprintf(w, "    done\n\n")
+	fmt.Fprintf(w, "    case $cmd in\n")
+	for _, n := range nodes {
+		fmt.Fprintf(w, "        %s)\n", n.id)
+		fmt.Fprintf(w, "            flags=(\n")
+		for _, e := range n.flags {
+			for _, t := range e.flagTokens() {
+				fmt.Fprintf(w, "                %s\n", zshDescribe(t, e.def.Usage))
+			}
+		}
+		fmt.Fprintf(w, "            )\n")
+		fmt.Fprintf(w, "            commands=(\n")
+		for _, sub := range n.subs {
+			for _, k := range append([]string{sub.Name}, sub.Aliases...) {
+				fmt.Fprintf(w, "                %s\n", zshDescribe(k, commandSummary(sub)))
+			}
+		}
+		fmt.Fprintf(w, "            )\n")
+		staticFlags, dynamicFlags := n.splitValueFlags()
+		if len(staticFlags) > 0 || len(dynamicFlags) > 0 {
+			fmt.Fprintf(w, "            case ${words[CURRENT-1]} in\n")
+			if len(dynamicFlags) > 0 {
+				fmt.Fprintf(w, "                %s) __%s_dynamic; return ;;\n", strings.Join(dynamicFlags, "|"), fn)
+			}
+			if len(staticFlags) > 0 {
+				fmt.Fprintf(w, "                %s) _files; return ;;\n", strings.Join(staticFlags, "|"))
+			}
+			fmt.Fprintf(w, "            esac\n")
+		}
+		if n.dynamicArgs {
+			fmt.Fprintf(w, "            [[ ${words[CURRENT]} != -* ]] && { __%s_dynamic; return; }\n", fn)
+		} else if len(n.cmd.Args) > 0 {
+			fmt.Fprintf(w, "            [[ ${words[CURRENT]} != -* ]] && _message %s && _files\n", shQuote("arguments: "+argsUsage(n.cmd.Args)))
+		}
+		fmt.Fprintf(w, "            ;;\n")
+	}
+	fmt.Fprintf(w, "    esac\n\n")
+	fmt.Fprintf(w, "    if [[ ${words[CURRENT]} == -* ]]; then\n")
+	fmt.Fprintf(w, "        _describe -t flags 'flags' flags\n")
+	fmt.Fprintf(w, "    else\n")
+	fmt.Fprintf(w, "        _describe -t commands 'commands' commands\n")
+	fmt.Fprintf(w, "    fi\n")
+	fmt.Fprintf(w, "}\n\n")
+	fmt.Fprintf(w, "compdef _%s %s\n", fn, name)
+}
+
+func writeFishCompletion(w io.Writer, name, fn string, nodes []*completionNode) {
+	fmt.Fprintf(w, "# fish completion for %s\n\n", name)
+	fmt.Fprintf(w, "function __%s_command\n", fn)
+	fmt.Fprintf(w, "    set -l cmd %s\n", nodes[0].id)
+	fmt.Fprintf(w, "    for word in (commandline -opc)[2..-1]\n")
+	fmt.Fprintf(w, "        switch \"$cmd,$word\"\n")
+	for _, n := range nodes {
+		for _, k := range n.keys {
+			fmt.Fprintf(w, "            case %s\n", shQuote(n.id+","+k))
+			fmt.Fprintf(w, "                set cmd %s\n", n.next[k])
+		}
+	}
+	fmt.Fprintf(w, "        end\n")
+	fmt.Fprintf(w, "    end\n")
+	fmt.Fprintf(w, "    echo $cmd\n")
+	fmt.Fprintf(w, "end\n\n")
+	fmt.Fprintf(w, "function __%s_dynamic\n", fn)
+	fmt.Fprintf(w, "    set -l tokens (commandline -opc)\n")
+	fmt.Fprintf(w, "    set -l directive 0\n")
+	fmt.Fprintf(w, "    for line in ($tokens[1] %s $tokens[2..-1] (commandline -ct) 2>/dev/null)\n", completeCommandName)
+	fmt.Fprintf(w, "        if string match -q -- ':*' $line\n")
+	fmt.Fprintf(w, "            set directive (string sub -s 2 -- $line)\n")
+	fmt.Fprintf(w, "        else\n")
+	fmt.Fprintf(w, "            echo $line\n")
+	fmt.Fprintf(w, "        end\n")
+	fmt.Fprintf(w, "    end\n")
+	fmt.Fprintf(w, "    if test (math \"bitand($directive, %d)\") -ne 0\n", CompleteDirs)
+	fmt.Fprintf(w, "        __fish_complete_directories (commandline -ct)\n")
+	fmt.Fprintf(w, "    else if test (math \"bitand($directive, %d)\") -ne 0\n", CompleteFiles)
+	fmt.Fprintf(w, "        __fish_complete_path (commandline -ct)\n")
+	fmt.Fprintf(w, "    end\n")
+	fmt.Fprintf(w, "end\n\n")
+
+	for _, n := range nodes {
+		cond := shQuote(fmt.Sprintf("test (__%s_command) = %s", fn, n.id))
+		if len(n.cmd.Args) == 0 || n.dynamicArgs {
+			fmt.Fprintf(w, "complete -c %s -n %s -f\n", name, cond)
+		}
+		if n.dynamicArgs {
+			fmt.Fprintf(w, "complete -c %s -n %s -a %s\n", name, cond, shQuote("(__"+fn+"_dynamic)"))
+		}
+		for _, sub := range n.subs {
+			for _, k := range append([]string{sub.Name}, sub.Aliases...) {
+				line := fmt.Sprintf("complete -c %s -n %s -a %s", name, cond, shQuote(k))
+				if desc := commandSummary(sub); desc != "" {
+					line += " -d " + shQuote(desc)
+				}
+				fmt.Fprintln(w, line)
+			}
+		}
+		for _, e := range n.flags {
+			line := fmt.Sprintf("complete -c %s -n %s", name, cond)
+			if len(e.def.Name) > 1 {
+				line += " -l " + shQuote(e.def.Name)
+			}
+			if short := e.def.Short; short != "" {
+				line += " -s " + shQuote(short)
+			} else if len(e.def.Name) == 1 {
+				line += " -s " + shQuote(e.def.Name)
+			}
+			switch {
+			case e.isBool():
+			case e.completesValue():
+				line += " -r -f -a " + shQuote("(__"+fn+"_dynamic)")
+			default:
+				line += " -r -F"
+			}
+			if e.def.Usage != "" {
+				line += " -d " + shQuote(e.def.Usage)
+			}
+			fmt.Fprintln(w, line)
+		}
+	}
+}
+
+func psQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+func writePowerShellCompletion(w io.Writer, name string, nodes []*completionNode) {
+	fmt.Fprintf(w, "# powershell completion for %s\n\n", name)
+	fmt.Fprintf(w, "Register-ArgumentCompleter -Native -CommandName %s -ScriptBlock {\n", psQuote(name))
+	fmt.Fprintf(w, "    param($wordToComplete, $commandAst, $cursorPosition)\n\n")
+	fmt.Fprintf(w, "    $program = $commandAst.CommandElements[0].ToString()\n")
+	fmt.Fprintf(w, "    $words = @($commandAst.CommandElements | Select-Object -Skip 1 | ForEach-Object { $_.ToString() })\n")
+	fmt.Fprintf(w, "    if ($wordToComplete -ne '' -and $words.Count -gt 0) { $words = @($words | Select-Object -SkipLast 1) }\n\n")
+	fmt.Fprintf(w, "    $cmd = %s\n", psQuote(nodes[0].id))
+	fmt.Fprintf(w, "    $prev = ''\n")
+	fmt.Fprintf(w, "    foreach ($word in $words) {\n")
+	fmt.Fprintf(w, "        $prev = $word\n")
+	fmt.Fprintf(w, "        switch -CaseSensitive (\"$cmd,$word\") {\n")
+	for _, n := range nodes {
+		for _, k := range n.keys {
+			fmt.Fprintf(w, "            %s { $cmd = %s }\n", psQuote(n.id+","+k), psQuote(n.next[k]))
+		}
+	}
+	fmt.Fprintf(w, "        }\n")
+	fmt.Fprintf(w, "    }\n\n")
+	fmt.Fprintf(w, "    $valueFlags = @()\n")
+	fmt.Fprintf(w, "    $dynamicFlags = @()\n")
+	fmt.Fprintf(w, "    $dynamicArgs = $false\n")
+	fmt.Fprintf(w, "    $candidates = switch ($cmd) {\n")
+	for _, n := range nodes {
+		fmt.Fprintf(w, "        %s {\n", psQuote(n.id))
+		for _, sub := range n.subs {
+			for _, k := range append([]string{sub.Name}, sub.Aliases...) {
+				fmt.Fprintf(w, "            [System.Management.Automation.CompletionResult]::new(%s, %s, 'Command', %s)\n", psQuote(k), psQuote(k), psQuote(orDash(commandSummary(sub))))
+			}
+		}
+		for _, e := range n.flags {
+			for _, t := range e.flagTokens() {
+				fmt.Fprintf(w, "            [System.Management.Automation.CompletionResult]::new(%s, %s, 'ParameterName', %s)\n", psQuote(t), psQuote(t), psQuote(orDash(e.def.Usage)))
+			}
+		}
+		staticFlags, dynamicFlags := n.splitValueFlags()
+		if len(staticFlags) > 0 {
+			fmt.Fprintf(w, "            $valueFlags = @(%s)\n", psQuoteList(staticFlags))
+		}
+		if len(dynamicFlags) > 0 {
+			fmt.Fprintf(w, "            $dynamicFlags = @(%s)\n", psQuoteList(dynamicFlags))
+		}
+		if n.dynamicArgs {
+			fmt.Fprintf(w, "            $dynamicArgs = $true\n")
+		}
+		fmt.Fprintf(w, "        }\n")
+	}
+	fmt.Fprintf(w, "    }\n\n")
+	fmt.Fprintf(w, "    if (($dynamicFlags -ccontains $prev) -or ($dynamicArgs -and -not $wordToComplete.StartsWith('-'))) {\n")
+	fmt.Fprintf(w, "        # Older PowerShell drops empty arguments to native commands.\n")
+	fmt.Fprintf(w, "        $current = $wordToComplete\n")
+	fmt.Fprintf(w, "        if ($current -eq '' -and $PSNativeCommandArgumentPassing -ne 'Standard') { $current = '\"\"' }\n")
+	fmt.Fprintf(w, "        $directive = 0\n")
+	fmt.Fprintf(w, "        $candidates = foreach ($line in @(& $program %s @words $current 2>$null)) {\n", completeCommandName)
+	fmt.Fprintf(w, "            if ($line.StartsWith(':')) { $directive = [int]$line.Substring(1); continue }\n")
+	fmt.Fprintf(w, "            $value, $desc = $line -split \"`t\", 2\n")
+	fmt.Fprintf(w, "            if (-not $desc) { $desc = $value }\n")
+	fmt.Fprintf(w, "            [System.Management.Automation.CompletionResult]::new($value, $value, 'ParameterValue', $desc)\n")
+	fmt.Fprintf(w, "        }\n")
+	fmt.Fprintf(w, "        # Returning nothing falls back to path completion.\n")
+	fmt.Fprintf(w, "        if ($directive -band %d) { return }\n", CompleteFiles|CompleteDirs)
+	fmt.Fprintf(w, "        return $candidates\n")
+	fmt.Fprintf(w, "    }\n\n")
+	fmt.Fprintf(w, "    # Fall back to path completion for flag values.\n")
+	fmt.Fprintf(w, "    if ($valueFlags -ccontains $prev) { return }\n")
+	fmt.Fprintf(w, "    $candidates | Where-Object { $_.CompletionText -like \"$wordToComplete*\" }\n")
+	fmt.Fprintf(w, "}\n")
+}
+
+func psQuoteList(items []string) string {
+	quoted := make([]string, len(items))
+	for i, item := range items {
+		quoted[i] = psQuote(item)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
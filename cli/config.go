@@ -0,0 +1,304 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const SourceConfig ValueSource = "config"
+
+// ConfigDecoder decodes a config file into v. json.Unmarshal and the
+// Unmarshal functions of most TOML and YAML packages fit this signature.
+type ConfigDecoder func(data []byte, v any) error
+
+type configLayer struct {
+	path   string
+	found  bool
+	values map[string]any
+}
+
+type configValue struct {
+	value any
+	path  string
+}
+
+// configSet is the merged view of every config layer, keyed by dotted path
+// (e.g. "build.output"). Later layers override earlier ones.
+type configSet struct {
+	layers []configLayer
+	values map[string]configValue
+}
+
+func (c *CLI) configEnabled() bool {
+	return len(c.configPaths) > 0 || c.configFlag != ""
+}
+
+// loadConfig reads the well-known config paths, which may be missing, and
+// then the explicit path, which must exist.
+func (c *CLI) loadConfig(explicit string) (*configSet, error) {
+	set := &configSet{values: map[string]configValue{}}
+
+	paths := append([]string(nil), c.configPaths...)
+	if explicit != "" {
+		paths = append(paths, explicit)
+	}
+
+	for i, p := range paths {
+		layer, err := c.readConfigFile(p)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) && (explicit == "" || i < len(paths)-1) {
+				set.layers = append(set.layers, configLayer{path: layer.path})
+				continue
+			}
+			return nil, err
+		}
+		set.layers = append(set.layers, layer)
+		for k, v := range layer.values {
+			set.values[k] = configValue{value: v, path: layer.path}
+		}
+	}
+
+	return set, nil
+}
+
+func (c *CLI) readConfigFile(path string) (configLayer, error) {
+	path = expandHome(path)
+	layer := configLayer{path: path}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return layer, err
+	}
+
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext == "" {
+		ext = ".json"
+	}
+	decode, ok := c.configDecoders[ext]
+	if !ok {
+		return layer, fmt.Errorf("config %s: no decoder registered for %q files", path, ext)
+	}
+
+	var raw map[string]any
+	if err := decode(data, &raw); err != nil {
+		return layer, fmt.Errorf("config %s: %w", path, err)
+	}
+
+	layer.found = true
+	layer.values = map[string]any{}
+	flattenConfig("", raw, layer.values)
+	return layer, nil
+}
+
+func flattenConfig(prefix string, in map[string]any, out map[string]any) {
+	for k, v := range in {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		switch v := v.(type) {
+		case map[string]any:
+			flattenConfig(key, v, out)
+		case map[any]any:
+			// Some YAML decoders produce maps with interface keys.
+			m := make(map[string]any, len(v))
+			for mk, mv := range v {
+				m[fmt.Sprint(mk)] = mv
+			}
+			flattenConfig(key, m, out)
+		default:
+			out[key] = v
+		}
+	}
+}
+
+// table gathers the values below key, which flattening split into
+// "key.name" entries, as "name=value" items for a map flag.
+func (cfg *configSet) table(key string) (configValue, bool) {
+	var names []string
+	for k := range cfg.values {
+		if strings.HasPrefix(k, key+".") {
+			names = append(names, k)
+		}
+	}
+	if len(names) == 0 {
+		return configValue{}, false
+	}
+	sort.Strings(names)
+
+	items := make([]any, len(names))
+	for i, k := range names {
+		items[i] = strings.TrimPrefix(k, key+".") + "=" + configString(cfg.values[k].value)
+	}
+	return configValue{value: items, path: cfg.values[names[0]].path}, true
+}
+
+func expandHome(path string) string {
+	rest, ok := strings.CutPrefix(path, "~/")
+	if !ok {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, rest)
+}
+
+// configKeys lists the keys that may configure e for the command at the end
+// of chain, most specific first. A flag declared on chain[i] is looked up
+// under every command path from the leaf up to chain[i]; the root command's
+// name is never part of a key.
+func configKeys(e *flagEntry, chain []*Command) []string {
+	var keys []string
+	for i := len(chain) - 1; i >= e.declaredAt; i-- {
+		parts := make([]string, 0, i+1)
+		for _, cmd := range chain[1 : i+1] {
+			parts = append(parts, cmd.Name)
+		}
+		keys = append(keys, strings.Join(append(parts, e.def.Name), "."))
+	}
+	return keys
+}
+
+// applyConfig fills every flag that was set neither on the command line nor
+// from the environment.
+func (fs *flagSet) applyConfig(cfg *configSet, chain []*Command) error {
+	if cfg == nil {
+		return nil
+	}
+
+	for _, e := range fs.entries {
+		if e.builtin || e.source != SourceDefault {
+			continue
+		}
+		for _, key := range configKeys(e, chain) {
+			cv, ok := cfg.values[key]
+			if !ok && e.def.Type == StringMapFlag {
+				cv, ok = cfg.table(key)
+			}
+			if !ok {
+				continue
+			}
+			if err := setConfigValue(e.value, cv.value); err != nil {
+				return fmt.Errorf("invalid value %v for flag --%s from %s (%s): %v", cv.value, e.def.Name, cv.path, key, err)
+			}
+			e.source = SourceConfig
+			break
+		}
+	}
+	return nil
+}
+
+func setConfigValue(v interface{ Set(string) error }, raw any) error {
+	if list, ok := raw.([]any); ok {
+		for _, item := range list {
+			if err := v.Set(configString(item)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return v.Set(configString(raw))
+}
+
+func configString(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+func (c *CLI) installConfigFlag() {
+	if c.configFlag == "" {
+		return
+	}
+
+	for _, f := range append(c.Root.FlagDefs, c.Root.PersistentFlags...) {
+		if f.Name == c.configFlag {
+			return
+		}
+	}
+
+	c.Root.PersistentFlags = append(c.Root.PersistentFlags, Flag{
+		Name:  c.configFlag,
+		Usage: "path to a config file",
+	})
+}
+
+func (c *CLI) installConfigCommand() {
+	if !c.configEnabled() || collides(c.Root, "config") {
+		return
+	}
+
+	show := &Command{
+		Name:        "show",
+		Description: "Show the config files that were read and the values they resolved to",
+		Summary:     "Show resolved configuration",
+		builtin:     true,
+		FlagDefs: []Flag{
+			{Name: "json", Type: BoolFlag, Usage: "print the resolved values as JSON"},
+		},
+		Handler: func(ctx context.Context) error {
+			flags := Flags(ctx)
+			explicit, _ := flags[c.configFlag].(string)
+			cfg, err := c.loadConfig(explicit)
+			if err != nil {
+				return err
+			}
+
+			if asJSON, _ := flags["json"].(bool); asJSON {
+				values := map[string]any{}
+				for k, v := range cfg.values {
+					values[k] = v.value
+				}
+				enc := json.NewEncoder(c.out)
+				enc.SetIndent("", "  ")
+				return enc.Encode(values)
+			}
+
+			fmt.Fprintln(c.out, "Files:")
+			for _, l := range cfg.layers {
+				if l.found {
+					fmt.Fprintf(c.out, "  %s\n", l.path)
+				} else {
+					fmt.Fprintf(c.out, "  %s (not found)\n", l.path)
+				}
+			}
+			fmt.Fprintln(c.out)
+
+			keys := make([]string, 0, len(cfg.values))
+			for k := range cfg.values {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+
+			fmt.Fprintln(c.out, "Values:")
+			for _, k := range keys {
+				v := cfg.values[k]
+				data, _ := json.Marshal(v.value)
+				fmt.Fprintf(c.out, "  %s = %s (%s)\n", k, data, v.path)
+			}
+			return nil
+		},
+	}
+
+	_ = c.RegisterCommand(nil, &Command{
+		Name:        "config",
+		Description: "Inspect configuration",
+		Summary:     "Inspect configuration",
+		builtin:     true,
+		Commands:    []*Command{show},
+	})
+}
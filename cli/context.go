@@ -5,17 +5,23 @@ import (
 	"io"
 	"log"
 	"strings"
+	"text/template"
+	"time"
 )
 
 type appKeyType struct{}
 type commandKeyType struct{}
 type argsKeyType struct{}
+type argValuesKeyType struct{}
 type flagsKeyType struct{}
+type flagSourcesKeyType struct{}
 
 var appKey = appKeyType{}
 var commandKey = commandKeyType{}
 var argsKey = argsKeyType{}
+var argValuesKey = argValuesKeyType{}
 var flagsKey = flagsKeyType{}
+var flagSourcesKey = flagSourcesKeyType{}
 
 type App struct {
 	Logger *log.Logger
@@ -55,6 +61,109 @@ func WithHelpCommandName(name string) Option {
 	}
 }
 
+func WithInterspersed(enabled bool) Option {
+	return func(c *CLI) {
+		c.interspersed = enabled
+	}
+}
+
+// WithSignalHandling cancels the command's context on the first SIGINT or
+// SIGTERM and gives After hooks up to grace to finish; a second signal exits
+// immediately. A grace of zero uses a 10 second default.
+func WithSignalHandling(grace time.Duration) Option {
+	return func(c *CLI) {
+		c.handleSignals = true
+		c.signalGrace = grace
+		if grace <= 0 {
+			c.signalGrace = defaultSignalGrace
+		}
+	}
+}
+
+// WithHelpRenderer replaces the way help is rendered.
+func WithHelpRenderer(r HelpRenderer) Option {
+	return func(c *CLI) {
+		c.helpRenderer = r
+	}
+}
+
+// WithHelpTemplate renders help with a text/template instead of
+// DefaultHelpTemplate. The template is executed with a *HelpData.
+func WithHelpTemplate(text string, funcs template.FuncMap) Option {
+	return func(c *CLI) {
+		c.helpRenderer = &TemplateRenderer{Text: text, Funcs: funcs}
+	}
+}
+
+// WithHelpWidth wraps help to n columns instead of the terminal's width.
+func WithHelpWidth(n int) Option {
+	return func(c *CLI) {
+		c.helpWidth = n
+	}
+}
+
+// WithSuggestions turns "did you mean" suggestions for unknown commands and
+// flags on or off. They are on by default.
+func WithSuggestions(enabled bool) Option {
+	return func(c *CLI) {
+		c.noSuggestions = !enabled
+	}
+}
+
+// WithSuggestionDistance sets the maximum edit distance between a mistyped
+// name and a suggestion. The default is 2.
+func WithSuggestionDistance(n int) Option {
+	return func(c *CLI) {
+		c.suggestionDistance = n
+	}
+}
+
+// WithEnvPrefix binds every flag to an environment variable named after the
+// prefix and the flag, e.g. MYAPP_OUTPUT for --output with prefix "MYAPP".
+func WithEnvPrefix(prefix string) Option {
+	return func(c *CLI) {
+		c.envPrefix = strings.TrimSuffix(prefix, "_")
+	}
+}
+
+// WithConfigPaths layers the given config files, later paths overriding
+// earlier ones. Missing files are skipped and a leading "~/" is expanded.
+func WithConfigPaths(paths ...string) Option {
+	return func(c *CLI) {
+		c.configPaths = append(c.configPaths, paths...)
+	}
+}
+
+// WithConfigFlag adds a persistent flag on the root command naming a config
+// file that is layered on top of the WithConfigPaths files.
+func WithConfigFlag(name string) Option {
+	return func(c *CLI) {
+		c.configFlag = strings.TrimLeft(name, "-")
+	}
+}
+
+// WithConfigDecoder registers a decoder for config files with the given
+// extension, e.g. WithConfigDecoder(".yaml", yaml.Unmarshal).
+func WithConfigDecoder(ext string, dec ConfigDecoder) Option {
+	return func(c *CLI) {
+		if dec == nil {
+			return
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		c.configDecoders[strings.ToLower(ext)] = dec
+	}
+}
+
+// WithCompletionCommand installs a "completion <shell>" command that prints
+// a completion script for bash, zsh, fish or powershell.
+func WithCompletionCommand() Option {
+	return func(c *CLI) {
+		c.completionCommand = true
+	}
+}
+
 func AppFromContext(ctx context.Context) *App {
 	if ctx == nil {
 		return nil
@@ -88,6 +197,44 @@ func Args(ctx context.Context) []string {
 	return v.([]string)
 }
 
+// ArgValue returns the converted value of the named positional argument, a
+// []any for variadic arguments, or nil when it was not given.
+func ArgValue(ctx context.Context, name string) any {
+	if ctx == nil {
+		return nil
+	}
+	v := ctx.Value(argValuesKey)
+	if v == nil {
+		return nil
+	}
+	return v.(map[string]any)[name]
+}
+
+func ArgString(ctx context.Context, name string) string {
+	v, _ := ArgValue(ctx, name).(string)
+	return v
+}
+
+func ArgInt(ctx context.Context, name string) int {
+	v, _ := ArgValue(ctx, name).(int)
+	return v
+}
+
+func ArgFloat(ctx context.Context, name string) float64 {
+	v, _ := ArgValue(ctx, name).(float64)
+	return v
+}
+
+func ArgBool(ctx context.Context, name string) bool {
+	v, _ := ArgValue(ctx, name).(bool)
+	return v
+}
+
+func ArgDuration(ctx context.Context, name string) time.Duration {
+	v, _ := ArgValue(ctx, name).(time.Duration)
+	return v
+}
+
 func Flags(ctx context.Context) map[string]any {
 	if ctx == nil {
 		return map[string]any{}
@@ -98,3 +245,15 @@ func Flags(ctx context.Context) map[string]any {
 	}
 	return v.(map[string]any)
 }
+
+// FlagSource reports where the value of the named flag came from.
+func FlagSource(ctx context.Context, name string) ValueSource {
+	if ctx == nil {
+		return ""
+	}
+	v := ctx.Value(flagSourcesKey)
+	if v == nil {
+		return ""
+	}
+	return v.(map[string]ValueSource)[name]
+}
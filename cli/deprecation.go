@@ -0,0 +1,165 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const helpAllFlagName = "help-all"
+
+// DeprecatedAlias is an alternative name of a command that still works but
+// warns when used. It is not listed in help.
+type DeprecatedAlias struct {
+	Name string
+	// Message tells users what to do instead, e.g. `use "remove"`.
+	Message string
+	// RemoveIn is the version the alias is going away in.
+	RemoveIn string
+}
+
+// listed reports whether cmd shows up in default help and completion.
+func (cmd *Command) listed() bool {
+	return !cmd.Hidden && cmd.Deprecated == ""
+}
+
+func (f Flag) listed() bool {
+	return !f.Hidden && f.Deprecated == ""
+}
+
+// deprecatedAlias returns the deprecated alias of cmd named token, if any.
+func deprecatedAlias(cmd *Command, token string) (DeprecatedAlias, bool) {
+	for _, a := range cmd.DeprecatedAliases {
+		if a.Name == token {
+			return a, true
+		}
+	}
+	return DeprecatedAlias{}, false
+}
+
+// deprecationNotice is the text after "is deprecated", e.g. " and will be
+// removed in 2.0: use --output instead".
+func deprecationNotice(message, removeIn string) string {
+	var s string
+	if removeIn != "" {
+		s = " and will be removed in " + removeIn
+	}
+	if message != "" {
+		s += ": " + message
+	}
+	return s
+}
+
+// warnDeprecated writes one warning for every deprecated command, alias and
+// flag used by the run. words are the tokens that selected chain[1:].
+func (c *CLI) warnDeprecated(chain []*Command, words []string, fs *flagSet) {
+	for i, cmd := range chain {
+		path := commandPath(chain[:i+1])
+		if i > 0 {
+			if a, ok := deprecatedAlias(cmd, words[i-1]); ok {
+				fmt.Fprintf(c.err, "Warning: alias %q of %q is deprecated%s\n", a.Name, path, deprecationNotice(a.Message, a.RemoveIn))
+			}
+		}
+		if cmd.Deprecated != "" {
+			fmt.Fprintf(c.err, "Warning: command %q is deprecated%s\n", path, deprecationNotice(cmd.Deprecated, cmd.RemoveIn))
+		}
+	}
+
+	for _, e := range fs.entries {
+		if e.def.Deprecated != "" && e.provided() {
+			fmt.Fprintf(c.err, "Warning: flag --%s is deprecated%s\n", e.def.Name, deprecationNotice(e.def.Deprecated, e.def.RemoveIn))
+		}
+	}
+}
+
+// VerifyDeprecations reports every deprecated command, alias and flag whose
+// RemoveIn version is version or older, so that a test fails once a
+// release is due to drop them. Versions are compared numerically by their
+// dot-separated parts; a leading "v" is ignored.
+func (c *CLI) VerifyDeprecations(version string) error {
+	var errs []error
+	check := func(what, removeIn string) {
+		if removeIn == "" {
+			return
+		}
+		due, err := versionReached(version, removeIn)
+		switch {
+		case err != nil:
+			errs = append(errs, fmt.Errorf("%s: %w", what, err))
+		case due:
+			errs = append(errs, fmt.Errorf("%s was due for removal in %s", what, removeIn))
+		}
+	}
+
+	var walk func(cmd *Command, parents []*Command)
+	walk = func(cmd *Command, parents []*Command) {
+		chain := append(parents[:len(parents):len(parents)], cmd)
+		path := commandPath(chain)
+
+		check(fmt.Sprintf("command %q", path), cmd.RemoveIn)
+		for _, a := range cmd.DeprecatedAliases {
+			check(fmt.Sprintf("alias %q of %q", a.Name, path), a.RemoveIn)
+		}
+		for _, f := range append(cmd.FlagDefs, cmd.PersistentFlags...) {
+			check(fmt.Sprintf("flag --%s of %q", f.Name, path), f.RemoveIn)
+		}
+		for _, sub := range cmd.Commands {
+			if sub != nil {
+				walk(sub, chain)
+			}
+		}
+	}
+	walk(c.Root, nil)
+
+	return errors.Join(errs...)
+}
+
+// versionReached reports whether current is at or past target.
+func versionReached(current, target string) (bool, error) {
+	cur, err := parseVersion(current)
+	if err != nil {
+		return false, err
+	}
+	want, err := parseVersion(target)
+	if err != nil {
+		return false, err
+	}
+
+	for i := range max(len(cur.parts), len(want.parts)) {
+		a, b := partAt(cur.parts, i), partAt(want.parts, i)
+		if a != b {
+			return a > b, nil
+		}
+	}
+	// 2.0.0-rc.1 comes before 2.0.0.
+	return cur.pre == "" || want.pre != "" && cur.pre >= want.pre, nil
+}
+
+type version struct {
+	parts []int
+	pre   string
+}
+
+func parseVersion(s string) (version, error) {
+	var v version
+	core := strings.TrimPrefix(s, "v")
+	core, _, _ = strings.Cut(core, "+")
+	core, v.pre, _ = strings.Cut(core, "-")
+
+	for _, p := range strings.Split(core, ".") {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return v, fmt.Errorf("invalid version %q", s)
+		}
+		v.parts = append(v.parts, n)
+	}
+	return v, nil
+}
+
+func partAt(parts []int, i int) int {
+	if i < len(parts) {
+		return parts[i]
+	}
+	return 0
+}
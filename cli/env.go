@@ -0,0 +1,71 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+type ValueSource string
+
+const (
+	SourceDefault ValueSource = "default"
+	SourceEnv     ValueSource = "env"
+	SourceFlag    ValueSource = "flag"
+)
+
+// envNames lists the environment variables bound to e in lookup order: the
+// explicitly declared names followed by the one derived from prefix.
+func (e *flagEntry) envNames(prefix string) []string {
+	if e.builtin {
+		return nil
+	}
+
+	names := append([]string(nil), e.def.Env...)
+	if prefix != "" {
+		names = append(names, envName(prefix, e.def.Name))
+	}
+	return names
+}
+
+func envName(prefix, name string) string {
+	name = strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(name))
+	return strings.ToUpper(prefix) + "_" + name
+}
+
+// applyEnv fills every flag that was not set on the command line from the
+// first of its bound environment variables that is present.
+func (fs *flagSet) applyEnv() error {
+	for _, e := range fs.entries {
+		if e.set {
+			continue
+		}
+		for _, name := range e.envNames(fs.envPrefix) {
+			v, ok := os.LookupEnv(name)
+			if !ok {
+				continue
+			}
+			if err := e.value.Set(v); err != nil {
+				return fmt.Errorf("invalid value %q for flag --%s from $%s: %v", v, e.def.Name, name, err)
+			}
+			e.source = SourceEnv
+			break
+		}
+	}
+	return nil
+}
+
+func snapshotSources(fs *flagSet) map[string]ValueSource {
+	out := map[string]ValueSource{}
+	if fs == nil {
+		return out
+	}
+
+	for _, e := range fs.entries {
+		if e.builtin {
+			continue
+		}
+		out[e.def.Name] = e.source
+	}
+	return out
+}
@@ -0,0 +1,137 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ExitUsage is the exit code for errors in how the program was invoked.
+const ExitUsage = 2
+
+// UsageError reports a mistake in the command line: an unknown command or
+// flag, a missing or invalid argument or flag value. It wraps the specific
+// error, which may itself be an *UnknownCommandError or *MissingArgumentError.
+type UsageError struct {
+	// Command is the full path of the command that was being invoked,
+	// e.g. "myapp db migrate".
+	Command string
+	Err     error
+}
+
+func (e *UsageError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *UsageError) Unwrap() error {
+	return e.Err
+}
+
+// UnknownCommandError reports a word that names no subcommand of Command.
+type UnknownCommandError struct {
+	Command     string
+	Name        string
+	Suggestions []string
+}
+
+func (e *UnknownCommandError) Error() string {
+	return fmt.Sprintf("unknown command %q for %q%s", e.Name, e.Command, didYouMean(e.Suggestions))
+}
+
+// MissingArgumentError reports required positional arguments that were not
+// given.
+type MissingArgumentError struct {
+	Args []string
+}
+
+func (e *MissingArgumentError) Error() string {
+	names := make([]string, len(e.Args))
+	for i, a := range e.Args {
+		names[i] = "<" + a + ">"
+	}
+	return fmt.Sprintf("missing required arguments: %s", strings.Join(names, " "))
+}
+
+// ExitError makes the program exit with Code. Err, if set, is printed
+// before exiting.
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("exit status %d", e.Code)
+	}
+	return e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+// Exit returns an error that makes Main and RunAndExit exit with code after
+// printing msg. An empty msg exits silently.
+func Exit(code int, msg string) error {
+	e := &ExitError{Code: code}
+	if msg != "" {
+		e.Err = errors.New(msg)
+	}
+	return e
+}
+
+// ExitCode maps an error returned by Run to a process exit code: 0 for nil,
+// the code of an *ExitError, ExitUsage for a *UsageError and 1 otherwise.
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.Code
+	}
+	var usageErr *UsageError
+	if errors.As(err, &usageErr) {
+		return ExitUsage
+	}
+	return 1
+}
+
+func usageError(chain []*Command, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &UsageError{Command: commandPath(chain), Err: err}
+}
+
+func commandPath(chain []*Command) string {
+	names := make([]string, len(chain))
+	for i, cmd := range chain {
+		names[i] = cmd.Name
+	}
+	return strings.Join(names, " ")
+}
+
+// Main runs the program with the process arguments and exits.
+func (c *CLI) Main() {
+	c.RunAndExit(os.Args[1:])
+}
+
+// RunAndExit runs args, prints any error to the error writer and exits with
+// the code ExitCode maps it to. Usage errors are followed by a pointer to
+// the command's help.
+func (c *CLI) RunAndExit(args []string) {
+	err := c.Run(args)
+	if err != nil {
+		var exitErr *ExitError
+		if !errors.As(err, &exitErr) || exitErr.Err != nil {
+			fmt.Fprintf(c.err, "Error: %s\n", err)
+		}
+		var usageErr *UsageError
+		if errors.As(err, &usageErr) && usageErr.Command != "" {
+			fmt.Fprintf(c.err, "Run '%s --help' for usage.\n", usageErr.Command)
+		}
+	}
+	c.exit(ExitCode(err))
+}
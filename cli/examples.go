@@ -0,0 +1,120 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// VerifyExamples parses the invocation of every example in the command tree
+// without running anything. Each must resolve to the command it documents
+// and pass flag parsing, argument count and argument conversion checks.
+// It is meant to be called from a test:
+//
+//	func TestExamples(t *testing.T) {
+//		if err := newCLI().VerifyExamples(); err != nil {
+//			t.Fatal(err)
+//		}
+//	}
+func (c *CLI) VerifyExamples() error {
+	var errs []error
+
+	var walk func(cmd *Command, parents []*Command)
+	walk = func(cmd *Command, parents []*Command) {
+		chain := append(parents[:len(parents):len(parents)], cmd)
+		for _, ex := range cmd.Examples {
+			if err := c.verifyExample(chain, ex.Invocation); err != nil {
+				errs = append(errs, fmt.Errorf("%s: example %q: %w", commandPath(chain), ex.Invocation, err))
+			}
+		}
+		for _, sub := range cmd.Commands {
+			if sub != nil {
+				walk(sub, chain)
+			}
+		}
+	}
+	walk(c.Root, nil)
+
+	return errors.Join(errs...)
+}
+
+func (c *CLI) verifyExample(chain []*Command, invocation string) error {
+	words, err := splitCommandLine(invocation)
+	if err != nil {
+		return err
+	}
+	if len(words) == 0 || words[0] != c.Root.Name {
+		return fmt.Errorf("must start with %q", c.Root.Name)
+	}
+
+	cmd, parents, args, _ := resolveCommand(c.Root, nil, words[1:])
+	if cmd != chain[len(chain)-1] {
+		got := commandPath(append(parents, cmd))
+		return fmt.Errorf("runs %q instead", got)
+	}
+
+	fs, _, err := c.newFlagSet(cmd, parents)
+	if err != nil {
+		return err
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := validatePositionalArgs(cmd, fs.Args()); err != nil {
+		return err
+	}
+	_, err = convertArgs(cmd, fs.Args())
+	return err
+}
+
+// splitCommandLine splits s into words like a POSIX shell would, honouring
+// single and double quotes and backslash escapes.
+func splitCommandLine(s string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	var quote rune
+
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\\' && i+1 < len(runes) && (quote == 0 || strings.ContainsRune(`"\$`+"`", runes[i+1])):
+			i++
+			word.WriteRune(runes[i])
+			inWord = true
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
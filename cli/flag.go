@@ -0,0 +1,351 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/netip"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type FlagType string
+
+const (
+	StringFlag   FlagType = "string"
+	BoolFlag     FlagType = "bool"
+	IntFlag      FlagType = "int"
+	FloatFlag    FlagType = "float"
+	DurationFlag FlagType = "duration"
+	PathFlag     FlagType = "path"
+
+	// StringSliceFlag and IntSliceFlag collect values from repeated flags,
+	// each of which may hold a comma-separated list: --tag a --tag b,c.
+	StringSliceFlag FlagType = "strings"
+	IntSliceFlag    FlagType = "ints"
+	// StringMapFlag collects key=value pairs the same way into a
+	// map[string]string: --label env=prod,team=core.
+	StringMapFlag FlagType = "map"
+	// CountFlag is an int incremented each time the flag is given: -vvv.
+	CountFlag FlagType = "count"
+	// EnumFlag is a string restricted to the flag's Choices.
+	EnumFlag FlagType = "enum"
+	// ByteSizeFlag is an int64 number of bytes written with an optional
+	// decimal (KB, MB, ...) or binary (KiB, MiB, ...) unit: 512MiB.
+	ByteSizeFlag FlagType = "bytes"
+	// TimeFlag is a time.Time written in RFC 3339 or as a date, 2006-01-02.
+	TimeFlag FlagType = "time"
+	// URLFlag is an absolute *url.URL.
+	URLFlag FlagType = "url"
+	// IPFlag is a netip.Addr.
+	IPFlag FlagType = "ip"
+)
+
+type Flag struct {
+	Name     string
+	Short    string
+	Env      []string
+	Type     FlagType
+	Default  any
+	Usage    string
+	Required bool
+	Hidden   bool
+	// Choices lists the values an EnumFlag accepts. They are shown in help
+	// and offered by shell completion.
+	Choices []string
+	// Deprecated marks the flag as deprecated with a message telling users
+	// what to use instead. It still works but warns when set, and is only
+	// listed in help with --help-all.
+	Deprecated string
+	// RemoveIn is the version a deprecated flag is going away in.
+	RemoveIn string
+
+	// Complete offers dynamic shell completion candidates for the value.
+	Complete CompleteFunc
+}
+
+// kind resolves the flag type when Type is unset: flags with Choices are
+// enums, others are inferred from Default.
+func (f Flag) kind() FlagType {
+	if f.Type != "" {
+		return f.Type
+	}
+	if len(f.Choices) > 0 {
+		return EnumFlag
+	}
+
+	switch f.Default.(type) {
+	case bool:
+		return BoolFlag
+	case int, int64:
+		return IntFlag
+	case float64:
+		return FloatFlag
+	case time.Duration:
+		return DurationFlag
+	case []string:
+		return StringSliceFlag
+	case []int:
+		return IntSliceFlag
+	case map[string]string:
+		return StringMapFlag
+	case time.Time:
+		return TimeFlag
+	case *url.URL:
+		return URLFlag
+	case netip.Addr:
+		return IPFlag
+	}
+
+	return StringFlag
+}
+
+func (f Flag) newValue() (flag.Getter, error) {
+	var v flag.Getter
+
+	switch f.kind() {
+	case StringFlag:
+		v = newScalarValue("", func(s string) (string, error) { return s, nil })
+	case BoolFlag:
+		v = &boolValue{}
+	case IntFlag:
+		v = newScalarValue(0, strconv.Atoi)
+	case FloatFlag:
+		v = newScalarValue(0.0, func(s string) (float64, error) { return strconv.ParseFloat(s, 64) })
+	case DurationFlag:
+		v = newScalarValue(time.Duration(0), time.ParseDuration)
+	case PathFlag:
+		v = newScalarValue("", func(s string) (string, error) { return filepath.Clean(expandHome(s)), nil })
+	case StringSliceFlag:
+		v = newSliceValue(func(s string) (string, error) { return s, nil }, func(s string) string { return s })
+	case IntSliceFlag:
+		v = newSliceValue(strconv.Atoi, strconv.Itoa)
+	case StringMapFlag:
+		v = &mapValue{}
+	case CountFlag:
+		v = &countValue{}
+	case EnumFlag:
+		if len(f.Choices) == 0 {
+			return nil, fmt.Errorf("flag %s: enum without choices", f.Name)
+		}
+		v = newScalarValue("", func(s string) (string, error) {
+			if !slices.Contains(f.Choices, s) {
+				return "", fmt.Errorf("must be one of %s", strings.Join(f.Choices, ", "))
+			}
+			return s, nil
+		})
+	case ByteSizeFlag:
+		v = &byteSizeValue{}
+	case TimeFlag:
+		v = &timeValue{}
+	case URLFlag:
+		v = &urlValue{}
+	case IPFlag:
+		v = &ipValue{}
+	default:
+		return nil, fmt.Errorf("flag %s: unknown type %q", f.Name, f.Type)
+	}
+
+	if f.Default != nil {
+		if err := v.Set(formatDefault(f.Default)); err != nil {
+			return nil, fmt.Errorf("flag %s: invalid default %v: %w", f.Name, f.Default, err)
+		}
+		// Repeatable flags start over when first given.
+		if r, ok := v.(interface{ markDefault() }); ok {
+			r.markDefault()
+		}
+	}
+
+	return v, nil
+}
+
+// formatDefault turns a Default into the text its flag type parses.
+func formatDefault(v any) string {
+	switch v := v.(type) {
+	case []string:
+		return strings.Join(v, ",")
+	case []int:
+		parts := make([]string, len(v))
+		for i, n := range v {
+			parts[i] = strconv.Itoa(n)
+		}
+		return strings.Join(parts, ",")
+	case map[string]string:
+		return formatMap(v)
+	case time.Time:
+		return v.Format(time.RFC3339)
+	}
+	return fmt.Sprint(v)
+}
+
+type scalarValue[T any] struct {
+	v     T
+	parse func(string) (T, error)
+}
+
+func newScalarValue[T any](zero T, parse func(string) (T, error)) *scalarValue[T] {
+	return &scalarValue[T]{v: zero, parse: parse}
+}
+
+func (s *scalarValue[T]) Set(str string) error {
+	v, err := s.parse(str)
+	if err != nil {
+		return err
+	}
+	s.v = v
+	return nil
+}
+
+func (s *scalarValue[T]) String() string {
+	if s == nil || s.parse == nil {
+		return ""
+	}
+	return fmt.Sprint(s.v)
+}
+
+func (s *scalarValue[T]) Get() any {
+	return s.v
+}
+
+type boolValue struct {
+	v bool
+}
+
+func (b *boolValue) Set(s string) error {
+	v, err := strconv.ParseBool(s)
+	if err != nil {
+		return err
+	}
+	b.v = v
+	return nil
+}
+
+func (b *boolValue) String() string {
+	if b == nil {
+		return "false"
+	}
+	return strconv.FormatBool(b.v)
+}
+
+func (b *boolValue) Get() any {
+	return b.v
+}
+
+func (b *boolValue) IsBoolFlag() bool {
+	return true
+}
+
+// newFlagSet builds the flagSet for cmd from its declarative flags, anything
+// added by its Flags callback, the persistent flags of cmd and its parents
+// and the built-in -h/--help flag. Flags declared on cmd shadow persistent
+// flags of the same name inherited from a parent.
+func newFlagSet(cmd *Command, parents []*Command) (*flagSet, *bool, error) {
+	fs := newEmptyFlagSet(cmd.Name)
+
+	if err := fs.addDefs(cmd.FlagDefs); err != nil {
+		return nil, nil, err
+	}
+
+	if cmd.Flags != nil {
+		std := flag.NewFlagSet(cmd.Name, flag.ContinueOnError)
+		std.SetOutput(io.Discard)
+		cmd.Flags(std)
+
+		var err error
+		std.VisitAll(func(f *flag.Flag) {
+			if err == nil {
+				err = fs.add(Flag{Name: f.Name, Usage: f.Usage}, f.Value)
+			}
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err := fs.addDefs(cmd.PersistentFlags); err != nil {
+		return nil, nil, err
+	}
+
+	for _, e := range fs.entries {
+		e.declaredAt = len(parents)
+	}
+
+	for i := len(parents) - 1; i >= 0; i-- {
+		for _, f := range parents[i].PersistentFlags {
+			if fs.lookup(f.Name) != nil {
+				continue
+			}
+			if _, ok := fs.short[f.Short]; ok {
+				f.Short = ""
+			}
+			if err := fs.addDefs([]Flag{f}); err != nil {
+				return nil, nil, err
+			}
+			e := fs.lookup(f.Name)
+			e.inherited = true
+			e.declaredAt = i
+		}
+	}
+
+	showHelp := &boolValue{}
+	help := Flag{Name: helpFlagName, Type: BoolFlag, Usage: "show help"}
+	if _, ok := fs.short["h"]; !ok {
+		help.Short = "h"
+	}
+	if fs.lookup(helpFlagName) == nil {
+		_ = fs.add(help, showHelp)
+		fs.lookup(helpFlagName).builtin = true
+	}
+	if fs.lookup(helpAllFlagName) == nil {
+		_ = fs.add(Flag{Name: helpAllFlagName, Type: BoolFlag, Usage: "show help including deprecated commands and flags"}, &boolValue{})
+		fs.lookup(helpAllFlagName).builtin = true
+	}
+
+	return fs, &showHelp.v, nil
+}
+
+func (fs *flagSet) addDefs(defs []Flag) error {
+	for _, f := range defs {
+		v, err := f.newValue()
+		if err != nil {
+			return err
+		}
+		f.Type = f.kind()
+		if err := fs.add(f, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// persistentFlagSpan reports how many leading arguments form a persistent
+// flag visible to cmd, so they can be skipped while looking for a subcommand.
+func persistentFlagSpan(cmd *Command, parents []*Command, args []string) int {
+	fs := newEmptyFlagSet(cmd.Name)
+	for _, p := range append(parents[:len(parents):len(parents)], cmd) {
+		for _, f := range p.PersistentFlags {
+			if fs.lookup(f.Name) != nil {
+				continue
+			}
+			if _, ok := fs.short[f.Short]; ok {
+				f.Short = ""
+			}
+			_ = fs.addDefs([]Flag{f})
+		}
+	}
+	return fs.span(args)
+}
+
+func validateRequiredFlags(fs *flagSet) error {
+	for _, e := range fs.entries {
+		if e.def.Required && !e.provided() {
+			return fmt.Errorf("missing required flag: --%s", e.def.Name)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,129 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FlagGroupKind is the rule a FlagGroup enforces.
+type FlagGroupKind string
+
+const (
+	// FlagsRequired requires every flag of the group.
+	FlagsRequired FlagGroupKind = "required"
+	// FlagsExclusive allows at most one flag of the group.
+	FlagsExclusive FlagGroupKind = "exclusive"
+	// FlagsOneRequired requires at least one flag of the group.
+	FlagsOneRequired FlagGroupKind = "one_required"
+	// FlagsRequiredTogether requires all flags of the group or none.
+	FlagsRequiredTogether FlagGroupKind = "required_together"
+)
+
+// FlagGroup constrains which flags of a command may or must be set
+// together. A flag counts as set when it has a value from the command line,
+// the environment or a config file, as for Flag.Required. Flags may be the
+// command's own or persistent flags of its parents.
+type FlagGroup struct {
+	Kind  FlagGroupKind
+	Flags []string
+	// If makes the rule conditional on this flag being set.
+	If string
+}
+
+func (g FlagGroup) names() string {
+	return "--" + strings.Join(g.Flags, ", --")
+}
+
+// describe is the help text of the rule, e.g. "at least one is required".
+func (g FlagGroup) describe() string {
+	var s string
+	switch g.Kind {
+	case FlagsRequired:
+		s = "required"
+	case FlagsExclusive:
+		s = "at most one may be set"
+	case FlagsOneRequired:
+		s = "at least one is required"
+	case FlagsRequiredTogether:
+		s = "must be set together"
+	default:
+		s = string(g.Kind)
+	}
+	if g.If != "" {
+		s += " when --" + g.If + " is set"
+	}
+	return s
+}
+
+// validateFlagGroups checks the FlagGroups of the last command in chain
+// against the parsed flags. Broken rules are usage errors; groups naming
+// unknown flags are reported as they are.
+func validateFlagGroups(chain []*Command, fs *flagSet) error {
+	cmd := chain[len(chain)-1]
+	isSet := func(name string) (bool, error) {
+		e := fs.lookup(name)
+		if e == nil {
+			return false, fmt.Errorf("flag group of %s: unknown flag --%s", cmd.Name, name)
+		}
+		return e.provided(), nil
+	}
+
+	for _, g := range cmd.FlagGroups {
+		if g.If != "" {
+			on, err := isSet(g.If)
+			if err != nil {
+				return err
+			}
+			if !on {
+				continue
+			}
+		}
+
+		var set, unset []string
+		for _, name := range g.Flags {
+			ok, err := isSet(name)
+			if err != nil {
+				return err
+			}
+			if ok {
+				set = append(set, "--"+name)
+			} else {
+				unset = append(unset, "--"+name)
+			}
+		}
+
+		when := ""
+		if g.If != "" {
+			when = " when --" + g.If + " is set"
+		}
+
+		switch g.Kind {
+		case FlagsRequired:
+			if len(unset) > 0 {
+				return usageError(chain, fmt.Errorf("missing required flag%s: %s%s", plural(unset), strings.Join(unset, ", "), when))
+			}
+		case FlagsExclusive:
+			if len(set) > 1 {
+				return usageError(chain, fmt.Errorf("flags %s cannot be used together", strings.Join(set, " and ")))
+			}
+		case FlagsOneRequired:
+			if len(set) == 0 {
+				return usageError(chain, fmt.Errorf("at least one of the flags %s is required%s", g.names(), when))
+			}
+		case FlagsRequiredTogether:
+			if len(set) > 0 && len(unset) > 0 {
+				return usageError(chain, fmt.Errorf("flags %s must be used together: missing %s", g.names(), strings.Join(unset, ", ")))
+			}
+		default:
+			return fmt.Errorf("flag group of %s: unknown kind %q", cmd.Name, g.Kind)
+		}
+	}
+	return nil
+}
+
+func plural(s []string) string {
+	if len(s) == 1 {
+		return ""
+	}
+	return "s"
+}
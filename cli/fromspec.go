@@ -0,0 +1,209 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+)
+
+// FromSpec builds a CLI from a JSON spec in the format produced by Spec and
+// binds handlers to its commands by path, e.g. "myapp db migrate". It fails
+// if a command that can run has no handler or a handler matches no command.
+// For YAML or TOML, use ParseSpec and Spec.Build.
+func FromSpec(r io.Reader, handlers map[string]Handler, opts ...Option) (*CLI, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	spec, err := ParseSpec(data, nil)
+	if err != nil {
+		return nil, err
+	}
+	root, err := spec.Build(handlers)
+	if err != nil {
+		return nil, err
+	}
+	return New(root, opts...), nil
+}
+
+// ParseSpec decodes a spec with decode, or as JSON if decode is nil. Any
+// ConfigDecoder works, so specs can be written in YAML or TOML. Unknown
+// fields are rejected.
+func ParseSpec(data []byte, decode ConfigDecoder) (Spec, error) {
+	var spec Spec
+
+	if decode != nil {
+		var raw any
+		if err := decode(data, &raw); err != nil {
+			return spec, fmt.Errorf("spec: %w", err)
+		}
+		var err error
+		if data, err = json.Marshal(jsonKeys(raw)); err != nil {
+			return spec, fmt.Errorf("spec: %w", err)
+		}
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&spec); err != nil {
+		return spec, fmt.Errorf("spec: %w", err)
+	}
+	if spec.Version != "" && spec.Version != SpecVersion {
+		return spec, fmt.Errorf("spec: unsupported version %q, want %q", spec.Version, SpecVersion)
+	}
+	if spec.Command.Name == "" {
+		return spec, errors.New("spec: root command has no name")
+	}
+	return spec, nil
+}
+
+// jsonKeys turns the map[any]any some YAML decoders produce into maps JSON
+// can encode.
+func jsonKeys(v any) any {
+	switch v := v.(type) {
+	case map[any]any:
+		m := make(map[string]any, len(v))
+		for k, e := range v {
+			m[fmt.Sprint(k)] = jsonKeys(e)
+		}
+		return m
+	case map[string]any:
+		for k, e := range v {
+			v[k] = jsonKeys(e)
+		}
+	case []any:
+		for i, e := range v {
+			v[i] = jsonKeys(e)
+		}
+	}
+	return v
+}
+
+// Build turns the spec into a command tree. Builtin commands are skipped, as
+// New installs them again. Commands marked runnable and commands without
+// subcommands must have a handler, and every handler must match a command.
+func (s Spec) Build(handlers map[string]Handler) (*Command, error) {
+	var errs []error
+	used := map[string]bool{}
+
+	var build func(cs CommandSpec, parent string) *Command
+	build = func(cs CommandSpec, parent string) *Command {
+		path := strings.TrimSpace(parent + " " + cs.Name)
+		cmd := &Command{
+			Name:         cs.Name,
+			Summary:      cs.Summary,
+			Description:  cs.Description,
+			Long:         cs.Long,
+			Hidden:       cs.Hidden,
+			Deprecated:   cs.Deprecated,
+			RemoveIn:     cs.RemoveIn,
+			Aliases:      cs.Aliases,
+			Group:        cs.Group,
+			Order:        cs.Order,
+			Interspersed: cs.Interspersed,
+			SeeAlso:      cs.SeeAlso,
+		}
+
+		if h, ok := handlers[path]; ok {
+			cmd.Handler = h
+			used[path] = true
+		} else if cs.Runnable || !hasSpecCommands(cs) {
+			errs = append(errs, fmt.Errorf("spec: command %q has no handler", path))
+		}
+
+		for _, a := range cs.DeprecatedAliases {
+			cmd.DeprecatedAliases = append(cmd.DeprecatedAliases, DeprecatedAlias(a))
+		}
+		for _, g := range cs.Groups {
+			cmd.Groups = append(cmd.Groups, CommandGroup{ID: g.ID, Title: g.Title})
+		}
+		for _, a := range cs.Args {
+			cmd.Args = append(cmd.Args, Arg{
+				Name:        a.Name,
+				Description: a.Description,
+				Optional:    a.Optional,
+				Variadic:    a.Variadic,
+				Type:        a.Type,
+				Default:     specValue(a.Type, a.Default),
+				Choices:     a.Choices,
+			})
+		}
+		for _, f := range cs.Flags {
+			cmd.FlagDefs = append(cmd.FlagDefs, specFlag(f))
+		}
+		for _, f := range cs.PersistentFlags {
+			cmd.PersistentFlags = append(cmd.PersistentFlags, specFlag(f))
+		}
+		for _, g := range cs.FlagGroups {
+			cmd.FlagGroups = append(cmd.FlagGroups, FlagGroup{Kind: g.Kind, Flags: g.Flags, If: g.If})
+		}
+		for _, ex := range cs.Examples {
+			cmd.Examples = append(cmd.Examples, Example{Description: ex.Description, Invocation: ex.Invocation})
+		}
+		for _, sub := range cs.Commands {
+			if !sub.Builtin {
+				cmd.Commands = append(cmd.Commands, build(sub, path))
+			}
+		}
+		return cmd
+	}
+
+	root := build(s.Command, "")
+
+	var unused []string
+	for name := range handlers {
+		if !used[name] {
+			unused = append(unused, name)
+		}
+	}
+	sort.Strings(unused)
+	for _, name := range unused {
+		errs = append(errs, fmt.Errorf("spec: handler %q matches no command", name))
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return root, nil
+}
+
+// hasSpecCommands reports whether cs has subcommands other than builtin
+// ones; commands without any must have a handler.
+func hasSpecCommands(cs CommandSpec) bool {
+	for _, sub := range cs.Commands {
+		if !sub.Builtin {
+			return true
+		}
+	}
+	return false
+}
+
+func specFlag(f FlagSpec) Flag {
+	return Flag{
+		Name:     f.Name,
+		Short:    f.Short,
+		Env:      f.Env,
+		Type:     f.Type,
+		Default:  specValue(f.Type, f.Default),
+		Usage:    f.Usage,
+		Required: f.Required,
+		Hidden:   f.Hidden,
+		Choices:  f.Choices,
+
+		Deprecated: f.Deprecated,
+		RemoveIn:   f.RemoveIn,
+	}
+}
+
+// specValue undoes JSON decoding every number as a float64, which would
+// otherwise print large integer defaults in exponent form.
+func specValue(t FlagType, v any) any {
+	if f, ok := v.(float64); ok && t == IntFlag && f == math.Trunc(f) {
+		return int(f)
+	}
+	return v
+}
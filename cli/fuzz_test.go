@@ -121,6 +121,39 @@ func FuzzValidatePositionalArgs(f *testing.F) {
 	})
 }
 
+func FuzzFlagSetParse(f *testing.F) {
+	f.Add("-xvf archive.tar")
+	f.Add("--output=dist --no-cache")
+	f.Add("-odist -- -v")
+	f.Add("--no-output")
+	f.Add("-o")
+	f.Add("--=")
+	f.Add("-=")
+	f.Add("---x")
+
+	f.Fuzz(func(t *testing.T, input string) {
+		cmd := &Command{
+			Name: "test",
+			FlagDefs: []Flag{
+				{Name: "verbose", Short: "v", Type: BoolFlag},
+				{Name: "extract", Short: "x", Type: BoolFlag},
+				{Name: "file", Short: "f"},
+				{Name: "output", Short: "o"},
+				{Name: "count", Type: IntFlag},
+				{Name: "cache", Type: BoolFlag, Default: true},
+			},
+		}
+
+		fs, _, err := newFlagSet(cmd, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_ = fs.Parse(splitArgs(input))
+		_ = snapshotFlags(fs)
+	})
+}
+
 func FuzzFindSubcommand(f *testing.F) {
 	f.Add("sub")
 	f.Add("alias1")
@@ -0,0 +1,504 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+const defaultHelpWidth = 80
+
+// DefaultHelpTemplate is the text/template used to render help unless a
+// CLI is given another template or renderer. Besides the standard template
+// functions it may call:
+//
+//	columns ENTRIES   two aligned columns, descriptions wrapped to the width
+//	wrap INDENT TEXT  TEXT word-wrapped to the width, each line indented
+//	join LIST SEP     strings.Join
+const DefaultHelpTemplate = `{{.Name}}{{with .Summary}} - {{.}}{{end}}
+
+{{with .Description}}{{wrap 0 .}}
+
+{{end}}{{with .Deprecated}}{{wrap 0 (print "Deprecated: " .)}}
+
+{{end}}Usage:
+{{range .Usage}}  {{.}}
+{{end}}
+{{with .Aliases}}Aliases:
+  {{join . ", "}}
+
+{{end}}{{with .Examples}}Examples:
+{{range $i, $e := .}}{{if $i}}
+{{end}}{{with $e.Description}}{{wrap 2 (print "# " .)}}
+{{end}}  {{$e.Invocation}}
+{{end}}
+{{end}}{{with .Args}}Arguments:
+{{columns .}}
+
+{{end}}{{with .Flags}}Flags:
+{{columns .}}
+
+{{end}}{{with .GlobalFlags}}Global Flags:
+{{columns .}}
+
+{{end}}{{with .FlagRules}}Flag Rules:
+{{columns .}}
+
+{{end}}{{range .CommandGroups}}{{.Title}}:
+{{columns .Entries}}
+
+{{end}}{{with .SeeAlso}}See Also:
+{{columns .}}
+
+{{end}}{{with .Footer}}{{wrap 0 .}}
+{{end}}`
+
+// HelpEntry is one row of a help section: an argument, flag or command.
+type HelpEntry struct {
+	Name  string
+	Usage string
+}
+
+// HelpSection is a titled list of entries, such as a group of commands.
+type HelpSection struct {
+	Title   string
+	Entries []HelpEntry
+}
+
+// HelpData is everything a HelpRenderer needs to render help for Command.
+type HelpData struct {
+	Command *Command
+	// Parents are the commands above Command, starting at the root.
+	Parents []*Command
+
+	Name string
+	// Path is the full invocation path, e.g. "myapp db migrate".
+	Path    string
+	Summary string
+	// Description is the command's Long text if set, else its Description.
+	Description string
+	// Deprecated tells users the command is deprecated and what to use
+	// instead, if it is.
+	Deprecated string
+	// Usage holds one line per way of invoking the command, e.g.
+	// "myapp db migrate [flags] <version>" and "myapp db [command]".
+	Usage   []string
+	Aliases []string
+	// Footer points at help for the subcommands, if there are any.
+	Footer string
+
+	Args        []HelpEntry
+	Flags       []HelpEntry
+	GlobalFlags []HelpEntry
+	// FlagRules describes the command's FlagGroups, e.g. "--file, --stdin"
+	// with "at most one may be set".
+	FlagRules []HelpEntry
+	// Commands lists every visible subcommand in display order. Deprecated
+	// commands, flags and aliases are only listed with --help-all.
+	Commands []HelpEntry
+	// CommandGroups splits Commands into the parent's groups, followed by
+	// ungrouped commands. Without groups it is a single "Commands" section.
+	CommandGroups []HelpSection
+	Examples      []Example
+	SeeAlso       []HelpEntry
+
+	// Width is the number of columns to wrap to.
+	Width int
+}
+
+// HelpRenderer writes help for a command.
+type HelpRenderer interface {
+	RenderHelp(w io.Writer, data *HelpData) error
+}
+
+// HelpRendererFunc adapts a function to HelpRenderer.
+type HelpRendererFunc func(w io.Writer, data *HelpData) error
+
+func (f HelpRendererFunc) RenderHelp(w io.Writer, data *HelpData) error {
+	return f(w, data)
+}
+
+// TemplateRenderer renders help with a text/template. Text defaults to
+// DefaultHelpTemplate and Funcs are added to the columns and wrap functions.
+type TemplateRenderer struct {
+	Text  string
+	Funcs template.FuncMap
+}
+
+func (r *TemplateRenderer) RenderHelp(w io.Writer, data *HelpData) error {
+	text := r.Text
+	if text == "" {
+		text = DefaultHelpTemplate
+	}
+
+	funcs := template.FuncMap{
+		"columns": func(entries []HelpEntry) string {
+			return formatColumns(entries, data.Width)
+		},
+		"wrap": func(indent int, s string) string {
+			return strings.Join(wrapText(s, indent, data.Width), "\n")
+		},
+		"join": strings.Join,
+	}
+	for k, v := range r.Funcs {
+		funcs[k] = v
+	}
+
+	tmpl, err := template.New("help").Funcs(funcs).Parse(text)
+	if err != nil {
+		return fmt.Errorf("help template: %w", err)
+	}
+	return tmpl.Execute(w, data)
+}
+
+func (c *CLI) printHelp(cmd *Command, parents []*Command) error {
+	return c.renderHelp(cmd, parents, false)
+}
+
+// renderHelp writes help for cmd; all includes deprecated commands, flags
+// and aliases.
+func (c *CLI) renderHelp(cmd *Command, parents []*Command, all bool) error {
+	if cmd == nil {
+		return nil
+	}
+
+	data, err := c.helpData(cmd, parents, all)
+	if err != nil {
+		return err
+	}
+
+	r := c.helpRenderer
+	if r == nil {
+		r = &TemplateRenderer{}
+	}
+	return r.RenderHelp(c.out, data)
+}
+
+// HelpDataFor returns the data help is rendered from for cmd, whose
+// ancestors starting at the root are parents. Documentation generators use
+// it to stay in line with help output.
+func (c *CLI) HelpDataFor(cmd *Command, parents []*Command) (*HelpData, error) {
+	return c.helpData(cmd, parents, false)
+}
+
+func (c *CLI) helpData(cmd *Command, parents []*Command, all bool) (*HelpData, error) {
+	data := &HelpData{
+		Command:     cmd,
+		Parents:     parents,
+		Name:        cmd.Name,
+		Path:        commandPath(append(parents[:len(parents):len(parents)], cmd)),
+		Summary:     cmd.Summary,
+		Description: cmd.Description,
+		Aliases:     cmd.Aliases,
+		Examples:    cmd.Examples,
+		Width:       c.helpWidth,
+	}
+	if cmd.Long != "" {
+		data.Description = cmd.Long
+	}
+	if cmd.Deprecated != "" {
+		data.Deprecated = cmd.Deprecated
+		if cmd.RemoveIn != "" {
+			data.Deprecated += " (to be removed in " + cmd.RemoveIn + ")"
+		}
+	}
+	if all {
+		data.Aliases = slices.Clone(cmd.Aliases)
+		for _, a := range cmd.DeprecatedAliases {
+			data.Aliases = append(data.Aliases, a.Name+" (deprecated)")
+		}
+	}
+	if data.Width <= 0 {
+		data.Width = terminalWidth(c.out)
+	}
+
+	for _, a := range cmd.Args {
+		usage := a.Description
+		if a.Optional {
+			usage += " (optional)"
+		}
+		if a.Variadic {
+			usage += " (variadic)"
+		}
+		if len(a.Choices) > 0 {
+			usage += " (one of: " + strings.Join(a.Choices, ", ") + ")"
+		}
+		if a.Default != nil {
+			usage += fmt.Sprintf(" (default %q)", formatDefault(a.Default))
+		}
+		data.Args = append(data.Args, HelpEntry{Name: a.Name, Usage: strings.TrimSpace(usage)})
+	}
+
+	fs, _, err := c.newFlagSet(cmd, parents)
+	if err != nil {
+		return nil, err
+	}
+
+	var local, inherited []*flagEntry
+	for _, e := range fs.entries {
+		if e.builtin || e.def.Hidden || e.def.Deprecated != "" && !all {
+			continue
+		}
+		if e.inherited {
+			inherited = append(inherited, e)
+		} else {
+			local = append(local, e)
+		}
+	}
+	data.Flags = flagHelpEntries(local, fs.envPrefix)
+	data.GlobalFlags = flagHelpEntries(inherited, fs.envPrefix)
+	for _, g := range cmd.FlagGroups {
+		data.FlagRules = append(data.FlagRules, HelpEntry{Name: g.names(), Usage: g.describe()})
+	}
+
+	var subs []*Command
+	for _, sub := range cmd.Commands {
+		if sub == nil || sub.Hidden || sub.Deprecated != "" && !all {
+			continue
+		}
+		subs = append(subs, sub)
+	}
+
+	hasSubs := len(subs) > 0
+	if cmd.Handler != nil || len(cmd.Args) > 0 || !hasSubs {
+		usage := data.Path
+		if len(local)+len(inherited) > 0 {
+			usage += " [flags]"
+		}
+		if len(cmd.Args) > 0 {
+			usage += " " + argsUsage(cmd.Args)
+		}
+		data.Usage = append(data.Usage, usage)
+	}
+	if hasSubs {
+		data.Usage = append(data.Usage, data.Path+" [command]")
+		data.Footer = c.helpFooter(cmd, parents)
+	}
+
+	sort.SliceStable(subs, func(i, j int) bool {
+		if subs[i].Name == c.HelpCommandName {
+			return true
+		}
+		if subs[j].Name == c.HelpCommandName {
+			return false
+		}
+		if subs[i].Order != subs[j].Order {
+			return subs[i].Order < subs[j].Order
+		}
+		return subs[i].Name < subs[j].Name
+	})
+	for _, sub := range subs {
+		data.Commands = append(data.Commands, HelpEntry{Name: sub.Name, Usage: shortDescription(sub)})
+	}
+	data.CommandGroups = groupCommands(cmd.Groups, subs)
+
+	for _, ref := range cmd.SeeAlso {
+		data.SeeAlso = append(data.SeeAlso, c.seeAlsoEntry(ref))
+	}
+
+	return data, nil
+}
+
+// seeAlsoEntry resolves a SeeAlso path, with or without the root command's
+// name, to its full path and summary.
+func (c *CLI) seeAlsoEntry(ref string) HelpEntry {
+	path := strings.Fields(ref)
+	if len(path) > 0 && path[0] == c.Root.Name {
+		path = path[1:]
+	}
+
+	chain, ok := c.findCommandPath(path...)
+	if !ok {
+		return HelpEntry{Name: strings.Join(append([]string{c.Root.Name}, path...), " "), Usage: "-"}
+	}
+
+	return HelpEntry{Name: commandPath(chain), Usage: shortDescription(chain[len(chain)-1])}
+}
+
+// groupCommands splits subs, already in display order, into sections in
+// the order the groups are declared. Commands without a known group come
+// last under "Other Commands", or under "Commands" if there are no groups.
+func groupCommands(groups []CommandGroup, subs []*Command) []HelpSection {
+	if len(subs) == 0 {
+		return nil
+	}
+
+	sections := make([]HelpSection, len(groups))
+	index := map[string]int{}
+	for i, g := range groups {
+		title := g.Title
+		if title == "" {
+			title = g.ID
+		}
+		sections[i].Title = title
+		index[g.ID] = i
+	}
+
+	other := HelpSection{Title: "Commands"}
+	if len(groups) > 0 {
+		other.Title = "Other Commands"
+	}
+	for _, sub := range subs {
+		entry := HelpEntry{Name: sub.Name, Usage: shortDescription(sub)}
+		if i, ok := index[sub.Group]; ok && sub.Group != "" {
+			sections[i].Entries = append(sections[i].Entries, entry)
+		} else {
+			other.Entries = append(other.Entries, entry)
+		}
+	}
+
+	var out []HelpSection
+	for _, section := range append(sections, other) {
+		if len(section.Entries) > 0 {
+			out = append(out, section)
+		}
+	}
+	return out
+}
+
+func shortDescription(cmd *Command) string {
+	s := "-"
+	if cmd.Summary != "" {
+		s = cmd.Summary
+	} else if cmd.Description != "" {
+		s = cmd.Description
+	}
+	if cmd.Deprecated != "" {
+		s += " (deprecated)"
+	}
+	return s
+}
+
+func (c *CLI) helpFooter(cmd *Command, parents []*Command) string {
+	chain := append(parents[:len(parents):len(parents)], cmd)
+	if c.helpCommand != nil {
+		path := append([]string{c.Root.Name, c.HelpCommandName}, strings.Fields(commandPath(chain[1:]))...)
+		return fmt.Sprintf("Use \"%s [command]\" for more information about a command.", strings.Join(path, " "))
+	}
+	return fmt.Sprintf("Use \"%s [command] --help\" for more information about a command.", commandPath(chain))
+}
+
+func flagHelpEntries(flags []*flagEntry, envPrefix string) []HelpEntry {
+	sort.Slice(flags, func(i, j int) bool {
+		return flags[i].def.Name < flags[j].def.Name
+	})
+
+	var entries []HelpEntry
+	for _, e := range flags {
+		suffix := fmt.Sprintf(" (default %q)", e.defValue)
+		if e.def.Required {
+			suffix = " (required)"
+		}
+		if env := e.envNames(envPrefix); len(env) > 0 {
+			suffix += " [$" + strings.Join(env, ", $") + "]"
+		}
+		if e.def.Deprecated != "" {
+			suffix += " (deprecated" + deprecationNotice(e.def.Deprecated, e.def.RemoveIn) + ")"
+		}
+		entries = append(entries, HelpEntry{Name: e.usageName(), Usage: strings.TrimSpace(e.def.Usage + suffix)})
+	}
+	return entries
+}
+
+const (
+	columnIndent = 2
+	columnGap    = 4
+	// minUsageWidth is the narrowest the description column may get before
+	// descriptions move to their own lines below the names.
+	minUsageWidth = 24
+)
+
+// formatColumns lays entries out as aligned name and description columns,
+// wrapping descriptions to width.
+func formatColumns(entries []HelpEntry, width int) string {
+	nameWidth := 0
+	for _, e := range entries {
+		nameWidth = max(nameWidth, len(e.Name))
+	}
+
+	usageIndent := columnIndent + nameWidth + columnGap
+	stacked := width-usageIndent < minUsageWidth
+	if stacked {
+		usageIndent = columnIndent + columnGap
+	}
+
+	var b strings.Builder
+	for i, e := range entries {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		lines := wrapText(e.Usage, usageIndent, width)
+		if stacked {
+			b.WriteString(strings.Repeat(" ", columnIndent) + e.Name)
+			for _, line := range lines {
+				b.WriteString("\n" + line)
+			}
+			continue
+		}
+
+		b.WriteString(strings.Repeat(" ", columnIndent))
+		b.WriteString(e.Name)
+		if len(lines) > 0 {
+			b.WriteString(strings.Repeat(" ", usageIndent-columnIndent-len(e.Name)))
+			b.WriteString(strings.TrimLeft(lines[0], " "))
+			for _, line := range lines[1:] {
+				b.WriteString("\n" + line)
+			}
+		}
+	}
+	return b.String()
+}
+
+// wrapText word-wraps s so that no line, including indent leading spaces,
+// is longer than width unless a single word does not fit. Existing line
+// breaks are kept.
+func wrapText(s string, indent, width int) []string {
+	if s == "" {
+		return nil
+	}
+
+	pad := strings.Repeat(" ", indent)
+	avail := width - indent
+
+	var lines []string
+	for _, para := range strings.Split(s, "\n") {
+		words := strings.Fields(para)
+		if len(words) == 0 || avail <= 0 {
+			lines = append(lines, strings.TrimRight(pad+para, " "))
+			continue
+		}
+
+		// Keep the paragraph's own indentation, e.g. for example blocks.
+		lead := para[:len(para)-len(strings.TrimLeft(para, " \t"))]
+		line := lead + words[0]
+		for _, word := range words[1:] {
+			if len(line)+1+len(word) > avail {
+				lines = append(lines, pad+line)
+				line = lead + word
+				continue
+			}
+			line += " " + word
+		}
+		lines = append(lines, pad+line)
+	}
+	return lines
+}
+
+// terminalWidth reports the width to wrap help written to w: $COLUMNS if
+// set, the terminal's width if w is one, and 80 otherwise.
+func terminalWidth(w io.Writer) int {
+	if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 0 {
+		return n
+	}
+	if f, ok := w.(*os.File); ok {
+		if n, ok := fileWidth(f); ok {
+			return n
+		}
+	}
+	return defaultHelpWidth
+}
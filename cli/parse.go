@@ -0,0 +1,334 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+)
+
+const helpFlagName = "help"
+
+type flagEntry struct {
+	def   Flag
+	value flag.Value
+	// defValue is the textual default captured before parsing.
+	defValue string
+	set      bool
+	builtin  bool
+	// inherited marks persistent flags declared on an ancestor command.
+	inherited bool
+	// declaredAt is the depth of the declaring command in the command path.
+	declaredAt int
+	source     ValueSource
+}
+
+// provided reports whether the flag was given a value, on the command line
+// or from the environment or a config file.
+func (e *flagEntry) provided() bool {
+	return e.source != SourceDefault
+}
+
+func (e *flagEntry) isBool() bool {
+	if b, ok := e.value.(interface{ IsBoolFlag() bool }); ok {
+		return b.IsBoolFlag()
+	}
+	return false
+}
+
+// flagSet is a GNU/POSIX style replacement for flag.FlagSet. Every flag is
+// reachable by its long name (--name) and optionally a single character
+// short name (-n). Short booleans may be bundled (-xvf), values may be
+// attached (--name=value, -nvalue, -n=value) or separate, --no-<name>
+// negates a boolean and "--" terminates flag parsing. Unless interspersed
+// is set, the first positional argument also terminates flag parsing.
+type flagSet struct {
+	name         string
+	entries      []*flagEntry
+	long         map[string]*flagEntry
+	short        map[string]*flagEntry
+	args         []string
+	interspersed bool
+	envPrefix    string
+	// suggestDistance bounds "did you mean" suggestions for unknown flags;
+	// below zero disables them.
+	suggestDistance int
+}
+
+func newEmptyFlagSet(name string) *flagSet {
+	return &flagSet{
+		name:            name,
+		long:            map[string]*flagEntry{},
+		short:           map[string]*flagEntry{},
+		suggestDistance: -1,
+	}
+}
+
+func (fs *flagSet) add(def Flag, value flag.Value) error {
+	if def.Name == "" {
+		return errors.New("flag name cannot be empty")
+	}
+	if _, ok := fs.long[def.Name]; ok {
+		return fmt.Errorf("flag redefined: %s", def.Name)
+	}
+	if def.Short != "" {
+		if len(def.Short) != 1 {
+			return fmt.Errorf("flag %s: short name must be a single character, got %q", def.Name, def.Short)
+		}
+		if _, ok := fs.short[def.Short]; ok {
+			return fmt.Errorf("flag redefined: -%s", def.Short)
+		}
+	}
+
+	e := &flagEntry{def: def, value: value, defValue: value.String(), source: SourceDefault}
+	fs.entries = append(fs.entries, e)
+	fs.long[def.Name] = e
+	if def.Short != "" {
+		fs.short[def.Short] = e
+	}
+	if len(def.Name) == 1 {
+		if _, ok := fs.short[def.Name]; !ok {
+			fs.short[def.Name] = e
+		}
+	}
+	return nil
+}
+
+// span reports how many leading arguments form a single flag known to fs,
+// including a separate value, or 0 if args does not start with such a flag.
+func (fs *flagSet) span(args []string) int {
+	if len(args) == 0 {
+		return 0
+	}
+
+	e, hasValue, ok := fs.resolveToken(args[0])
+	if !ok {
+		return 0
+	}
+	if hasValue || e.isBool() {
+		return 1
+	}
+	return min(2, len(args))
+}
+
+// resolveToken identifies the flag in token that would consume a following
+// value: the flag itself for long forms and the last flag of a short bundle.
+// hasValue reports whether the value is already part of token.
+func (fs *flagSet) resolveToken(token string) (e *flagEntry, hasValue bool, ok bool) {
+	if token == "--" || token == "-" || !strings.HasPrefix(token, "-") {
+		return nil, false, false
+	}
+
+	if body, ok := strings.CutPrefix(token, "--"); ok {
+		name, _, hasValue := strings.Cut(body, "=")
+		if e := fs.lookup(name); e != nil {
+			return e, hasValue, true
+		}
+		if neg, ok := strings.CutPrefix(name, "no-"); ok && !hasValue {
+			if e := fs.lookup(neg); e != nil && e.isBool() {
+				return e, true, true
+			}
+		}
+		return nil, false, false
+	}
+
+	body := token[1:]
+	if name, _, hasValue := strings.Cut(body, "="); len(name) > 1 {
+		if e := fs.lookup(name); e != nil {
+			return e, hasValue, true
+		}
+	}
+	for j := 0; j < len(body); j++ {
+		e = fs.short[body[j:j+1]]
+		if e == nil {
+			return nil, false, false
+		}
+		if !e.isBool() {
+			return e, j+1 < len(body), true
+		}
+		if strings.HasPrefix(body[j+1:], "=") {
+			return e, true, true
+		}
+	}
+	return e, false, e != nil
+}
+
+// suggest returns the visible long flags spelled like name.
+func (fs *flagSet) suggest(name string) []string {
+	var names []string
+	for _, e := range fs.entries {
+		if e.def.listed() {
+			names = append(names, e.def.Name)
+		}
+	}
+	out := suggest(name, names, fs.suggestDistance)
+	for i, s := range out {
+		out[i] = "--" + s
+	}
+	return out
+}
+
+func (fs *flagSet) lookup(name string) *flagEntry {
+	return fs.long[name]
+}
+
+func (fs *flagSet) Args() []string {
+	return fs.args
+}
+
+func (fs *flagSet) Parse(args []string) error {
+	fs.args = nil
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		switch {
+		case arg == "--":
+			fs.args = append(fs.args, args[i+1:]...)
+			return nil
+		case strings.HasPrefix(arg, "--"):
+			n, err := fs.parseLong(arg[2:], args[i+1:])
+			if err != nil {
+				return err
+			}
+			i += n
+		case strings.HasPrefix(arg, "-") && len(arg) > 1:
+			n, err := fs.parseShort(arg[1:], args[i+1:])
+			if err != nil {
+				return err
+			}
+			i += n
+		case fs.interspersed:
+			fs.args = append(fs.args, arg)
+		default:
+			fs.args = append(fs.args, args[i:]...)
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// parseLong handles the body of a --name[=value] token and returns how many
+// of the following arguments were consumed as its value.
+func (fs *flagSet) parseLong(body string, rest []string) (int, error) {
+	name, value, hasValue := strings.Cut(body, "=")
+	if name == "" {
+		return 0, fmt.Errorf("bad flag syntax: --%s", body)
+	}
+
+	e := fs.lookup(name)
+	if e == nil {
+		if neg, ok := strings.CutPrefix(name, "no-"); ok && !hasValue {
+			if e = fs.lookup(neg); e != nil && e.isBool() {
+				return 0, fs.set(e, "--"+name, "false")
+			}
+		}
+		return 0, fmt.Errorf("unknown flag: --%s%s", name, didYouMean(fs.suggest(name)))
+	}
+
+	return fs.consume(e, "--"+name, value, hasValue, rest)
+}
+
+// parseShort handles the body of a -abc token. A body naming a long flag
+// (-name or -name=value) is accepted for compatibility with the stdlib flag
+// package; otherwise each character is a short flag and the first non-bool
+// flag takes the remainder of the token, or the next argument, as its value.
+func (fs *flagSet) parseShort(body string, rest []string) (int, error) {
+	if name, value, hasValue := strings.Cut(body, "="); len(name) > 1 {
+		if e := fs.lookup(name); e != nil {
+			return fs.consume(e, "-"+name, value, hasValue, rest)
+		}
+	}
+
+	for j := 0; j < len(body); j++ {
+		c := body[j : j+1]
+		e := fs.short[c]
+		if e == nil {
+			return 0, fmt.Errorf("unknown shorthand flag: -%s", c)
+		}
+
+		remainder := body[j+1:]
+		if e.isBool() {
+			if v, ok := strings.CutPrefix(remainder, "="); ok {
+				return 0, fs.set(e, "-"+c, v)
+			}
+			if err := fs.set(e, "-"+c, "true"); err != nil {
+				return 0, err
+			}
+			continue
+		}
+
+		if remainder != "" {
+			return 0, fs.set(e, "-"+c, strings.TrimPrefix(remainder, "="))
+		}
+		return fs.consume(e, "-"+c, "", false, rest)
+	}
+
+	return 0, nil
+}
+
+func (fs *flagSet) consume(e *flagEntry, token, value string, hasValue bool, rest []string) (int, error) {
+	if hasValue {
+		return 0, fs.set(e, token, value)
+	}
+	if e.isBool() {
+		return 0, fs.set(e, token, "true")
+	}
+	if len(rest) == 0 {
+		return 0, fmt.Errorf("flag needs an argument: %s", token)
+	}
+	return 1, fs.set(e, token, rest[0])
+}
+
+func (fs *flagSet) set(e *flagEntry, token, value string) error {
+	if err := e.value.Set(value); err != nil {
+		return fmt.Errorf("invalid value %q for flag %s: %v", value, token, err)
+	}
+	e.set = true
+	e.source = SourceFlag
+	return nil
+}
+
+// usageName renders both forms of a flag for help output, e.g.
+// "-o, --output string".
+func (e *flagEntry) usageName() string {
+	var b strings.Builder
+	short := e.def.Short
+	if short == "" && len(e.def.Name) == 1 {
+		short = e.def.Name
+	}
+
+	if short != "" {
+		b.WriteString("-" + short)
+		if short != e.def.Name {
+			b.WriteString(", --" + e.def.Name)
+		}
+	} else {
+		b.WriteString("    --" + e.def.Name)
+	}
+
+	if placeholder := e.placeholder(); placeholder != "" {
+		b.WriteString(" " + placeholder)
+	}
+	return b.String()
+}
+
+func (e *flagEntry) placeholder() string {
+	if e.isBool() {
+		return ""
+	}
+	switch e.def.Type {
+	case "":
+	case EnumFlag:
+		return strings.Join(e.def.Choices, "|")
+	case StringMapFlag:
+		return "key=value"
+	case ByteSizeFlag:
+		return "size"
+	default:
+		return string(e.def.Type)
+	}
+	name, _ := flag.UnquoteUsage(&flag.Flag{Name: e.def.Name, Usage: e.def.Usage, Value: e.value})
+	return name
+}
@@ -14,10 +14,17 @@ type PluginRegistrar interface {
 }
 
 func (c *CLI) FindCommand(path ...string) (*Command, bool) {
-	if len(path) == 0 {
-		return c.Root, true
+	chain, ok := c.findCommandPath(path...)
+	if !ok {
+		return nil, false
 	}
+	return chain[len(chain)-1], true
+}
 
+// findCommandPath resolves path from the root and returns every command
+// along the way, starting with the root itself.
+func (c *CLI) findCommandPath(path ...string) ([]*Command, bool) {
+	chain := []*Command{c.Root}
 	cur := c.Root
 
 	for _, p := range path {
@@ -25,9 +32,10 @@ func (c *CLI) FindCommand(path ...string) (*Command, bool) {
 		if next == nil {
 			return nil, false
 		}
+		chain = append(chain, next)
 		cur = next
 	}
-	return cur, true
+	return chain, true
 }
 
 func (c *CLI) RegisterCommand(parentPath []string, cmd *Command) error {
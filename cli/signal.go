@@ -0,0 +1,75 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+const defaultSignalGrace = 10 * time.Second
+
+// notifySignals cancels ctx on the first SIGINT or SIGTERM. A second signal,
+// or the grace period running out while the command is still winding down,
+// exits the process immediately. stop must be called with the result of the
+// run; it releases the signal handler and turns a run that was interrupted
+// into an *ExitError with the conventional 128+signal code.
+func (c *CLI) notifySignals(parent context.Context) (context.Context, func(error) error) {
+	ctx, cancel := context.WithCancel(parent)
+
+	sigs := make(chan os.Signal, 2)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+
+	done := make(chan struct{})
+	received := make(chan os.Signal, 1)
+
+	go func() {
+		var sig os.Signal
+		select {
+		case sig = <-sigs:
+		case <-done:
+			return
+		}
+		received <- sig
+		cancel()
+
+		timer := time.NewTimer(c.signalGrace)
+		defer timer.Stop()
+		select {
+		case <-sigs:
+			c.exit(signalExitCode(sig))
+		case <-timer.C:
+			c.exit(signalExitCode(sig))
+		case <-done:
+		}
+	}()
+
+	stop := func(err error) error {
+		close(done)
+		signal.Stop(sigs)
+		cancel()
+
+		select {
+		case sig := <-received:
+			if errors.Is(err, context.Canceled) {
+				err = nil
+			}
+			return &ExitError{Code: signalExitCode(sig), Err: err}
+		default:
+			return err
+		}
+	}
+	return ctx, stop
+}
+
+// cleanupContext returns the context for After hooks. Once ctx has been
+// cancelled by a signal, hooks get an uncancelled context bounded by the
+// grace period instead, so they can still flush and release resources.
+func (c *CLI) cleanupContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if !c.handleSignals || ctx.Err() == nil {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(context.WithoutCancel(ctx), c.signalGrace)
+}
@@ -0,0 +1,15 @@
+//go:build !plan9
+
+package cli
+
+import (
+	"os"
+	"syscall"
+)
+
+func signalExitCode(sig os.Signal) int {
+	if s, ok := sig.(syscall.Signal); ok {
+		return 128 + int(s)
+	}
+	return 1
+}
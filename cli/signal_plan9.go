@@ -0,0 +1,10 @@
+//go:build plan9
+
+package cli
+
+import "os"
+
+// signalExitCode returns 1 on Plan 9, whose notes have no signal numbers.
+func signalExitCode(sig os.Signal) int {
+	return 1
+}
@@ -0,0 +1,252 @@
+package cli
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"flag"
+	"time"
+)
+
+// SpecVersion is the version of the spec format produced by Spec. It
+// changes whenever a field is removed or changes meaning.
+const SpecVersion = "1"
+
+const specCommandName = "__spec"
+
+//go:embed spec.schema.json
+var specSchema []byte
+
+// SpecSchema returns the JSON Schema describing the output of Spec.
+func SpecSchema() []byte {
+	return append([]byte(nil), specSchema...)
+}
+
+// Spec is a machine-readable description of a command tree.
+type Spec struct {
+	Version string      `json:"version"`
+	Command CommandSpec `json:"command"`
+}
+
+type CommandSpec struct {
+	Name            string          `json:"name"`
+	Summary         string          `json:"summary,omitempty"`
+	Description     string          `json:"description,omitempty"`
+	Long            string          `json:"long,omitempty"`
+	Hidden          bool            `json:"hidden,omitempty"`
+	Aliases         []string        `json:"aliases,omitempty"`
+	Group           string          `json:"group,omitempty"`
+	Order           int             `json:"order,omitempty"`
+	Groups          []GroupSpec     `json:"groups,omitempty"`
+	Args            []ArgSpec       `json:"args,omitempty"`
+	Flags           []FlagSpec      `json:"flags,omitempty"`
+	PersistentFlags []FlagSpec      `json:"persistent_flags,omitempty"`
+	FlagGroups      []FlagGroupSpec `json:"flag_groups,omitempty"`
+	Interspersed    bool            `json:"interspersed,omitempty"`
+	Examples        []ExampleSpec   `json:"examples,omitempty"`
+	SeeAlso         []string        `json:"see_also,omitempty"`
+
+	Deprecated        string                `json:"deprecated,omitempty"`
+	RemoveIn          string                `json:"remove_in,omitempty"`
+	DeprecatedAliases []DeprecatedAliasSpec `json:"deprecated_aliases,omitempty"`
+
+	// Runnable reports whether the command has a handler.
+	Runnable bool `json:"runnable,omitempty"`
+	// Builtin marks commands the CLI installs itself, such as help. FromSpec
+	// skips them since New adds them again.
+	Builtin  bool          `json:"builtin,omitempty"`
+	Commands []CommandSpec `json:"commands,omitempty"`
+}
+
+type GroupSpec struct {
+	ID    string `json:"id"`
+	Title string `json:"title,omitempty"`
+}
+
+type DeprecatedAliasSpec struct {
+	Name     string `json:"name"`
+	Message  string `json:"message,omitempty"`
+	RemoveIn string `json:"remove_in,omitempty"`
+}
+
+type ArgSpec struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Optional    bool     `json:"optional,omitempty"`
+	Variadic    bool     `json:"variadic,omitempty"`
+	Type        FlagType `json:"type"`
+	Default     any      `json:"default,omitempty"`
+	Choices     []string `json:"choices,omitempty"`
+}
+
+type FlagSpec struct {
+	Name     string   `json:"name"`
+	Short    string   `json:"short,omitempty"`
+	Env      []string `json:"env,omitempty"`
+	Type     FlagType `json:"type"`
+	Default  any      `json:"default,omitempty"`
+	Usage    string   `json:"usage,omitempty"`
+	Required bool     `json:"required,omitempty"`
+	Hidden   bool     `json:"hidden,omitempty"`
+	Choices  []string `json:"choices,omitempty"`
+
+	Deprecated string `json:"deprecated,omitempty"`
+	RemoveIn   string `json:"remove_in,omitempty"`
+}
+
+type FlagGroupSpec struct {
+	Kind  FlagGroupKind `json:"kind"`
+	Flags []string      `json:"flags"`
+	If    string        `json:"if,omitempty"`
+}
+
+type ExampleSpec struct {
+	Description string `json:"description,omitempty"`
+	Invocation  string `json:"invocation"`
+}
+
+// Spec describes the whole command tree, including hidden commands.
+func (c *CLI) Spec() Spec {
+	return Spec{Version: SpecVersion, Command: c.Root.Spec()}
+}
+
+// Spec describes cmd and the commands below it.
+func (cmd *Command) Spec() CommandSpec {
+	s := CommandSpec{
+		Name:         cmd.Name,
+		Summary:      cmd.Summary,
+		Description:  cmd.Description,
+		Long:         cmd.Long,
+		Hidden:       cmd.Hidden,
+		Deprecated:   cmd.Deprecated,
+		RemoveIn:     cmd.RemoveIn,
+		Aliases:      cmd.Aliases,
+		Group:        cmd.Group,
+		Order:        cmd.Order,
+		Interspersed: cmd.Interspersed,
+		SeeAlso:      cmd.SeeAlso,
+		Runnable:     cmd.Handler != nil,
+		Builtin:      cmd.builtin,
+	}
+
+	for _, a := range cmd.DeprecatedAliases {
+		s.DeprecatedAliases = append(s.DeprecatedAliases, DeprecatedAliasSpec(a))
+	}
+	for _, g := range cmd.Groups {
+		s.Groups = append(s.Groups, GroupSpec{ID: g.ID, Title: g.Title})
+	}
+	for _, a := range cmd.Args {
+		s.Args = append(s.Args, ArgSpec{
+			Name:        a.Name,
+			Description: a.Description,
+			Optional:    a.Optional,
+			Variadic:    a.Variadic,
+			Type:        a.kind(),
+			Default:     specDefault(a.Default),
+			Choices:     a.Choices,
+		})
+	}
+	for _, f := range cmd.FlagDefs {
+		s.Flags = append(s.Flags, flagSpec(f))
+	}
+	if cmd.Flags != nil {
+		std := flag.NewFlagSet(cmd.Name, flag.ContinueOnError)
+		cmd.Flags(std)
+		std.VisitAll(func(f *flag.Flag) {
+			s.Flags = append(s.Flags, FlagSpec{
+				Name:    f.Name,
+				Type:    getterType(f.Value),
+				Default: f.DefValue,
+				Usage:   f.Usage,
+			})
+		})
+	}
+	for _, f := range cmd.PersistentFlags {
+		s.PersistentFlags = append(s.PersistentFlags, flagSpec(f))
+	}
+	for _, g := range cmd.FlagGroups {
+		s.FlagGroups = append(s.FlagGroups, FlagGroupSpec{Kind: g.Kind, Flags: g.Flags, If: g.If})
+	}
+	for _, ex := range cmd.Examples {
+		s.Examples = append(s.Examples, ExampleSpec{Description: ex.Description, Invocation: ex.Invocation})
+	}
+	for _, sub := range cmd.Commands {
+		if sub != nil {
+			s.Commands = append(s.Commands, sub.Spec())
+		}
+	}
+	return s
+}
+
+func flagSpec(f Flag) FlagSpec {
+	return FlagSpec{
+		Name:     f.Name,
+		Short:    f.Short,
+		Env:      f.Env,
+		Type:     f.kind(),
+		Default:  specDefault(f.Default),
+		Usage:    f.Usage,
+		Required: f.Required,
+		Hidden:   f.Hidden,
+		Choices:  f.Choices,
+
+		Deprecated: f.Deprecated,
+		RemoveIn:   f.RemoveIn,
+	}
+}
+
+// specDefault keeps defaults that JSON can represent faithfully and turns
+// everything else, such as durations and lists, into the text the flag
+// would parse.
+func specDefault(v any) any {
+	switch v := v.(type) {
+	case nil, string, bool, int, int64, float64:
+		return v
+	}
+	return formatDefault(v)
+}
+
+// getterType guesses the type of a flag declared through the stdlib flag
+// package from its value.
+func getterType(v flag.Value) FlagType {
+	g, ok := v.(flag.Getter)
+	if !ok {
+		return StringFlag
+	}
+	switch g.Get().(type) {
+	case bool:
+		return BoolFlag
+	case int, int64, uint, uint64:
+		return IntFlag
+	case float64:
+		return FloatFlag
+	case time.Duration:
+		return DurationFlag
+	}
+	return StringFlag
+}
+
+func (c *CLI) installSpecCommand() {
+	if collides(c.Root, specCommandName) {
+		return
+	}
+
+	_ = c.RegisterCommand(nil, &Command{
+		Name:        specCommandName,
+		Description: "Print the command tree as JSON",
+		Hidden:      true,
+		builtin:     true,
+		FlagDefs: []Flag{
+			{Name: "schema", Type: BoolFlag, Usage: "print the JSON Schema of the spec instead"},
+		},
+		Handler: func(ctx context.Context) error {
+			if schema, _ := Flags(ctx)["schema"].(bool); schema {
+				_, err := c.out.Write(specSchema)
+				return err
+			}
+			enc := json.NewEncoder(c.out)
+			enc.SetIndent("", "  ")
+			return enc.Encode(c.Spec())
+		},
+	})
+}
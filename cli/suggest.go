@@ -0,0 +1,110 @@
+package cli
+
+import (
+	"sort"
+	"strings"
+)
+
+const defaultSuggestionDistance = 2
+
+// levenshtein returns the edit distance between a and b.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+// suggest returns the candidates within maxDistance edits of token, closest
+// first. Candidates that token is a prefix of are always included. A
+// maxDistance below zero disables suggestions.
+func suggest(token string, candidates []string, maxDistance int) []string {
+	if maxDistance < 0 || token == "" {
+		return nil
+	}
+
+	type match struct {
+		name     string
+		distance int
+	}
+	var matches []match
+	seen := map[string]bool{}
+	for _, cand := range candidates {
+		if seen[cand] || cand == token {
+			continue
+		}
+		d := levenshtein(strings.ToLower(token), strings.ToLower(cand))
+		if d <= maxDistance || strings.HasPrefix(cand, token) {
+			seen[cand] = true
+			matches = append(matches, match{cand, d})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].distance < matches[j].distance
+	})
+
+	out := make([]string, len(matches))
+	for i, m := range matches {
+		out[i] = m.name
+	}
+	return out
+}
+
+// didYouMean formats suggestions for appending to an error message.
+func didYouMean(suggestions []string) string {
+	if len(suggestions) == 0 {
+		return ""
+	}
+	return "\n\nDid you mean this?\n\t" + strings.Join(suggestions, "\n\t")
+}
+
+// commandNames lists the names and aliases of the visible subcommands of cmd.
+func commandNames(cmd *Command) []string {
+	var names []string
+	for _, sub := range cmd.Commands {
+		if sub == nil || !sub.listed() {
+			continue
+		}
+		names = append(names, sub.Name)
+		names = append(names, sub.Aliases...)
+	}
+	return names
+}
+
+func hasVisibleCommands(cmd *Command) bool {
+	return len(commandNames(cmd)) > 0
+}
+
+func (c *CLI) unknownCommandError(cmd *Command, parents []*Command, token string) error {
+	chain := append(parents[:len(parents):len(parents)], cmd)
+	return usageError(chain, &UnknownCommandError{
+		Command:     commandPath(chain),
+		Name:        token,
+		Suggestions: suggest(token, commandNames(cmd), c.suggestDistance()),
+	})
+}
+
+// suggestDistance is the maximum edit distance used for suggestions, or -1
+// when they are disabled.
+func (c *CLI) suggestDistance() int {
+	if c.noSuggestions {
+		return -1
+	}
+	return c.suggestionDistance
+}
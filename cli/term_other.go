@@ -0,0 +1,9 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package cli
+
+import "os"
+
+func fileWidth(f *os.File) (int, bool) {
+	return 0, false
+}
@@ -0,0 +1,27 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package cli
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// fileWidth returns the number of columns of the terminal f refers to.
+func fileWidth(f *os.File) (int, bool) {
+	conn, err := f.SyscallConn()
+	if err != nil {
+		return 0, false
+	}
+
+	var ws struct{ Row, Col, X, Y uint16 }
+	var errno syscall.Errno
+	err = conn.Control(func(fd uintptr) {
+		_, _, errno = syscall.Syscall(syscall.SYS_IOCTL, fd, uintptr(syscall.TIOCGWINSZ), uintptr(unsafe.Pointer(&ws)))
+	})
+	if err != nil || errno != 0 || ws.Col == 0 {
+		return 0, false
+	}
+	return int(ws.Col), true
+}
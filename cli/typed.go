@@ -0,0 +1,80 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// Handle returns a handler that passes in, a T populated from the running
+// command's flags and arguments, to fn. T is a struct whose fields are
+// tagged `flag:"name"` or `arg:"name"` as for Bind, but unlike Bind the
+// flags and arguments must already be declared on the command; tags other
+// than flag and arg are ignored.
+func Handle[T any](fn func(ctx context.Context, in T) error) Handler {
+	typ := reflect.TypeFor[T]()
+
+	var fields []boundField
+	var err error
+	if typ.Kind() != reflect.Struct {
+		err = fmt.Errorf("handle: %s is not a struct", typ)
+	} else {
+		err = structFields(typ, nil, &fields)
+	}
+
+	return func(ctx context.Context) error {
+		if err != nil {
+			return err
+		}
+		in, err := populate[T](ctx, fields)
+		if err != nil {
+			return err
+		}
+		return fn(ctx, in)
+	}
+}
+
+// FlagAs returns the value of the named flag as a T. It fails if the
+// command has no such flag or its value is not a T, rather than panicking
+// like an unchecked type assertion on Flags(ctx) would.
+func FlagAs[T any](ctx context.Context, name string) (T, error) {
+	var v T
+	raw, ok := Flags(ctx)[name]
+	if !ok {
+		return v, fmt.Errorf("flag --%s is not defined", name)
+	}
+	if err := setField(reflect.ValueOf(&v).Elem(), raw); err != nil {
+		return v, fmt.Errorf("flag --%s: %w", name, err)
+	}
+	return v, nil
+}
+
+// ArgAs returns the converted value of the i-th declared positional
+// argument as a T, a slice for a variadic argument. Commands without
+// declared arguments index the raw strings of Args instead. A missing
+// optional argument without a default yields the zero T.
+func ArgAs[T any](ctx context.Context, i int) (T, error) {
+	var v T
+
+	var raw any
+	if cmd := CurrentCommand(ctx); cmd != nil && len(cmd.Args) > 0 {
+		if i < 0 || i >= len(cmd.Args) {
+			return v, fmt.Errorf("argument %d is not defined", i)
+		}
+		raw = ArgValue(ctx, cmd.Args[i].Name)
+	} else {
+		args := Args(ctx)
+		if i < 0 || i >= len(args) {
+			return v, fmt.Errorf("argument %d was not given", i)
+		}
+		raw = args[i]
+	}
+
+	if raw == nil {
+		return v, nil
+	}
+	if err := setField(reflect.ValueOf(&v).Elem(), raw); err != nil {
+		return v, fmt.Errorf("argument %d: %w", i, err)
+	}
+	return v, nil
+}
@@ -0,0 +1,334 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sliceValue appends every comma-separated item of each Set to the list.
+type sliceValue[T any] struct {
+	v      []T
+	parse  func(string) (T, error)
+	format func(T) string
+	// fresh is set while v holds the default, so that the first Set
+	// replaces it instead of appending to it.
+	fresh bool
+}
+
+func newSliceValue[T any](parse func(string) (T, error), format func(T) string) *sliceValue[T] {
+	return &sliceValue[T]{parse: parse, format: format}
+}
+
+func (s *sliceValue[T]) Set(str string) error {
+	var items []T
+	if str != "" {
+		for _, part := range strings.Split(str, ",") {
+			v, err := s.parse(strings.TrimSpace(part))
+			if err != nil {
+				return err
+			}
+			items = append(items, v)
+		}
+	}
+	if s.fresh {
+		s.v, s.fresh = nil, false
+	}
+	s.v = append(s.v, items...)
+	return nil
+}
+
+func (s *sliceValue[T]) String() string {
+	if s == nil || s.format == nil {
+		return ""
+	}
+	parts := make([]string, len(s.v))
+	for i, v := range s.v {
+		parts[i] = s.format(v)
+	}
+	return strings.Join(parts, ",")
+}
+
+func (s *sliceValue[T]) Get() any {
+	if s.v == nil {
+		return []T{}
+	}
+	return slices.Clone(s.v)
+}
+
+func (s *sliceValue[T]) markDefault() {
+	s.fresh = true
+}
+
+// mapValue collects comma-separated key=value pairs.
+type mapValue struct {
+	v     map[string]string
+	fresh bool
+}
+
+func (m *mapValue) Set(str string) error {
+	if m.fresh || m.v == nil {
+		m.v, m.fresh = map[string]string{}, false
+	}
+	if str == "" {
+		return nil
+	}
+	for _, pair := range strings.Split(str, ",") {
+		k, v, ok := strings.Cut(pair, "=")
+		k = strings.TrimSpace(k)
+		if !ok || k == "" {
+			return fmt.Errorf("expected key=value, got %q", pair)
+		}
+		m.v[k] = v
+	}
+	return nil
+}
+
+func (m *mapValue) String() string {
+	if m == nil {
+		return ""
+	}
+	return formatMap(m.v)
+}
+
+func (m *mapValue) Get() any {
+	if m.v == nil {
+		return map[string]string{}
+	}
+	return maps.Clone(m.v)
+}
+
+func (m *mapValue) markDefault() {
+	m.fresh = true
+}
+
+func formatMap(m map[string]string) string {
+	pairs := make([]string, 0, len(m))
+	for _, k := range slices.Sorted(maps.Keys(m)) {
+		pairs = append(pairs, k+"="+m[k])
+	}
+	return strings.Join(pairs, ",")
+}
+
+// countValue is a boolean-style flag that counts how often it was given.
+// An explicit number sets the count and false resets it.
+type countValue struct {
+	v int
+}
+
+func (c *countValue) Set(s string) error {
+	switch s {
+	case "true":
+		c.v++
+	case "false":
+		c.v = 0
+	default:
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+		c.v = n
+	}
+	return nil
+}
+
+func (c *countValue) String() string {
+	if c == nil {
+		return "0"
+	}
+	return strconv.Itoa(c.v)
+}
+
+func (c *countValue) Get() any {
+	return c.v
+}
+
+func (c *countValue) IsBoolFlag() bool {
+	return true
+}
+
+var byteUnits = []struct {
+	suffix string
+	size   int64
+}{
+	{"EiB", 1 << 60}, {"PiB", 1 << 50}, {"TiB", 1 << 40}, {"GiB", 1 << 30}, {"MiB", 1 << 20}, {"KiB", 1 << 10},
+	{"EB", 1e18}, {"PB", 1e15}, {"TB", 1e12}, {"GB", 1e9}, {"MB", 1e6}, {"KB", 1e3},
+	{"B", 1},
+}
+
+// byteSizeValue is a non-negative number of bytes such as 512, 10MB or
+// 1.5GiB.
+type byteSizeValue struct {
+	v int64
+}
+
+func (b *byteSizeValue) Set(s string) error {
+	n, err := parseByteSize(s)
+	if err != nil {
+		return err
+	}
+	b.v = n
+	return nil
+}
+
+func (b *byteSizeValue) String() string {
+	if b == nil {
+		return "0"
+	}
+	return formatByteSize(b.v)
+}
+
+func (b *byteSizeValue) Get() any {
+	return b.v
+}
+
+func parseByteSize(s string) (int64, error) {
+	num, size := strings.TrimSpace(s), int64(1)
+	for _, u := range byteUnits {
+		if len(num) >= len(u.suffix) && strings.EqualFold(num[len(num)-len(u.suffix):], u.suffix) {
+			num, size = strings.TrimSpace(num[:len(num)-len(u.suffix)]), u.size
+			break
+		}
+	}
+
+	if n, err := strconv.ParseInt(num, 10, 64); err == nil {
+		if n < 0 {
+			return 0, fmt.Errorf("byte size %q is negative", s)
+		}
+		if n > math.MaxInt64/size {
+			return 0, fmt.Errorf("byte size %q out of range", s)
+		}
+		return n * size, nil
+	}
+	// Only plain decimals: ParseFloat would also take NaN, Inf and exponents.
+	f, err := strconv.ParseFloat(num, 64)
+	if err != nil || !isDecimal(num) {
+		return 0, fmt.Errorf("invalid byte size %q", s)
+	}
+	if f < 0 {
+		return 0, fmt.Errorf("byte size %q is negative", s)
+	}
+	f *= float64(size)
+	if f >= math.MaxInt64 {
+		return 0, fmt.Errorf("byte size %q out of range", s)
+	}
+	return int64(math.Round(f)), nil
+}
+
+// isDecimal reports whether s is an optionally signed number with at most
+// one decimal point.
+func isDecimal(s string) bool {
+	if s != "" && (s[0] == '+' || s[0] == '-') {
+		s = s[1:]
+	}
+	digits, point := 0, false
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			digits++
+		case r == '.' && !point:
+			point = true
+		default:
+			return false
+		}
+	}
+	return digits > 0
+}
+
+// formatByteSize writes n in the largest unit that divides it exactly, so
+// that the text parses back to n.
+func formatByteSize(n int64) string {
+	for _, u := range byteUnits {
+		if n != 0 && u.size > 1 && n%u.size == 0 {
+			return strconv.FormatInt(n/u.size, 10) + u.suffix
+		}
+	}
+	return strconv.FormatInt(n, 10)
+}
+
+var timeLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02 15:04:05", time.DateOnly}
+
+// timeValue accepts RFC 3339 timestamps and, in local time, dates with an
+// optional time of day.
+type timeValue struct {
+	v time.Time
+}
+
+func (t *timeValue) Set(s string) error {
+	for _, layout := range timeLayouts {
+		if v, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			t.v = v
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid time %q, want RFC 3339 or %s", s, time.DateOnly)
+}
+
+func (t *timeValue) String() string {
+	if t == nil || t.v.IsZero() {
+		return ""
+	}
+	return t.v.Format(time.RFC3339Nano)
+}
+
+func (t *timeValue) Get() any {
+	return t.v
+}
+
+type urlValue struct {
+	v *url.URL
+}
+
+func (u *urlValue) Set(s string) error {
+	v, err := url.Parse(s)
+	if err != nil {
+		return err
+	}
+	if v.Scheme == "" {
+		return errors.New("missing scheme")
+	}
+	u.v = v
+	return nil
+}
+
+func (u *urlValue) String() string {
+	if u == nil || u.v == nil {
+		return ""
+	}
+	return u.v.String()
+}
+
+func (u *urlValue) Get() any {
+	return u.v
+}
+
+type ipValue struct {
+	v netip.Addr
+}
+
+func (ip *ipValue) Set(s string) error {
+	v, err := netip.ParseAddr(s)
+	if err != nil {
+		return err
+	}
+	ip.v = v
+	return nil
+}
+
+func (ip *ipValue) String() string {
+	if ip == nil || !ip.v.IsValid() {
+		return ""
+	}
+	return ip.v.String()
+}
+
+func (ip *ipValue) Get() any {
+	return ip.v
+}
@@ -0,0 +1,146 @@
+// Package docs generates reference documentation from a flagon command
+// tree. Pages are built from the same data as the CLI's help output, and
+// hidden commands are left out.
+package docs
+
+import (
+	"strings"
+
+	"github.com/kingoftac/flagon/cli"
+)
+
+// page is one visible command together with its help data.
+type page struct {
+	chain []*cli.Command
+	help  *cli.HelpData
+}
+
+// pages walks the visible commands of c, parents before children.
+func pages(c *cli.CLI) ([]page, error) {
+	var out []page
+
+	var walk func(cmd *cli.Command, parents []*cli.Command) error
+	walk = func(cmd *cli.Command, parents []*cli.Command) error {
+		help, err := c.HelpDataFor(cmd, parents)
+		if err != nil {
+			return err
+		}
+		chain := append(parents[:len(parents):len(parents)], cmd)
+		out = append(out, page{chain: chain, help: help})
+
+		for _, sub := range cmd.Commands {
+			if sub == nil || sub.Hidden || sub.Deprecated != "" {
+				continue
+			}
+			if err := walk(sub, chain); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := walk(c.Root, nil); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func knownPaths(all []page) map[string]bool {
+	known := make(map[string]bool, len(all))
+	for _, p := range all {
+		known[p.help.Path] = true
+	}
+	return known
+}
+
+// seeAlso lists the paths of the parent, the visible subcommands and the
+// SeeAlso references of a page, without duplicates.
+func seeAlso(p page) []string {
+	var refs []string
+	seen := map[string]bool{p.help.Path: true}
+	add := func(path string) {
+		if !seen[path] {
+			seen[path] = true
+			refs = append(refs, path)
+		}
+	}
+
+	if len(p.chain) > 1 {
+		parent := make([]string, len(p.chain)-1)
+		for i, cmd := range p.chain[:len(p.chain)-1] {
+			parent[i] = cmd.Name
+		}
+		add(strings.Join(parent, " "))
+	}
+	for _, sub := range p.help.Commands {
+		add(p.help.Path + " " + sub.Name)
+	}
+	for _, ref := range p.help.SeeAlso {
+		add(ref.Name)
+	}
+	return refs
+}
+
+// related is seeAlso without the direct subcommands, for formats that
+// already link to them from their command list.
+func related(p page) []string {
+	var refs []string
+	prefix := p.help.Path + " "
+	for _, ref := range seeAlso(p) {
+		if rest, ok := strings.CutPrefix(ref, prefix); ok && !strings.Contains(rest, " ") {
+			continue
+		}
+		refs = append(refs, ref)
+	}
+	return refs
+}
+
+// baseName is the file name of a command's page without extension, e.g.
+// "myapp-db-migrate".
+func baseName(path string) string {
+	return strings.ReplaceAll(path, " ", "-")
+}
+
+// paragraphs splits a description into blocks separated by blank lines.
+// Blocks that are indented or fenced with ``` are reported as code.
+func paragraphs(text string) (blocks []string, code []bool) {
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+
+	var cur []string
+	isCode, fenced := false, false
+	flush := func() {
+		if len(cur) > 0 {
+			blocks = append(blocks, strings.Join(cur, "\n"))
+			code = append(code, isCode)
+		}
+		cur, isCode = nil, false
+	}
+
+	for _, line := range lines {
+		switch {
+		case strings.HasPrefix(strings.TrimSpace(line), "```"):
+			if fenced {
+				isCode = true
+				flush()
+				fenced = false
+			} else {
+				flush()
+				fenced = true
+			}
+		case fenced:
+			cur = append(cur, line)
+		case strings.TrimSpace(line) == "":
+			flush()
+		default:
+			if len(cur) == 0 {
+				isCode = strings.HasPrefix(line, "  ") || strings.HasPrefix(line, "\t")
+			}
+			cur = append(cur, line)
+		}
+	}
+	if fenced {
+		isCode = true
+	}
+	flush()
+	return blocks, code
+}
@@ -0,0 +1,239 @@
+package docs
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kingoftac/flagon/cli"
+	"github.com/kingoftac/flagon/lua"
+)
+
+func newTestCLI() *cli.CLI {
+	noop := func(ctx context.Context) error { return nil }
+	root := &cli.Command{
+		Name:    "myapp",
+		Summary: "Manage things",
+		PersistentFlags: []cli.Flag{
+			{Name: "verbose", Short: "v", Type: cli.BoolFlag, Usage: "verbose output"},
+		},
+		Commands: []*cli.Command{
+			{
+				Name:    "db",
+				Summary: "Database operations",
+				Commands: []*cli.Command{
+					{
+						Name:    "migrate",
+						Summary: "Run migrations",
+						Long:    "Run pending migrations.\n\n```\n.schema\n```",
+						Aliases: []string{"mig"},
+						Args:    []cli.Arg{{Name: "steps", Description: "how many", Optional: true}},
+						FlagDefs: []cli.Flag{
+							{Name: "output", Short: "o", Usage: "output directory"},
+							{Name: "secret", Hidden: true},
+							{Name: "quiet", Type: cli.BoolFlag},
+						},
+						FlagGroups: []cli.FlagGroup{{Kind: cli.FlagsExclusive, Flags: []string{"output", "quiet"}}},
+						Examples:   []cli.Example{{Description: "Apply all", Invocation: "myapp db migrate --output=out"}},
+						SeeAlso:    []string{"status"},
+						Handler:    noop,
+					},
+				},
+			},
+			{Name: "status", Summary: "Show status", Handler: noop},
+			{Name: "internal", Hidden: true, Handler: noop},
+		},
+	}
+	return cli.New(root, cli.WithWriters(&bytes.Buffer{}, &bytes.Buffer{}))
+}
+
+func TestGenMan(t *testing.T) {
+	c := newTestCLI()
+	db := c.Root.Commands[0]
+
+	var out bytes.Buffer
+	opts := ManOptions{Source: "myapp 1.0", Date: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)}
+	if err := GenMan(c, db.Commands[0], []*cli.Command{c.Root, db}, &out, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	page := out.String()
+	for _, want := range []string{
+		`.TH "MYAPP-DB-MIGRATE" "1" "Mar 2026" "myapp 1.0" "myapp Manual"`,
+		".SH NAME\nmyapp\\-db\\-migrate \\- Run migrations\n",
+		".SH SYNOPSIS\n\\fBmyapp db migrate\\fR [flags] [steps]\n",
+		".SH DESCRIPTION\n.PP\nRun pending migrations.\n.PP\n.RS\n.nf\n\\&.schema\n.fi\n.RE\n",
+		".SH ALIASES\nmig\n",
+		".SH ARGUMENTS\n.TP\n\\fBsteps\\fR\nhow many (optional)\n",
+		".SH OPTIONS\n.TP\n\\fB\\-o, \\-\\-output\\fR \\fIstring\\fR\noutput directory",
+		".SH GLOBAL OPTIONS\n.TP\n\\fB\\-v, \\-\\-verbose\\fR\nverbose output",
+		".SH FLAG RULES\n.TP\n\\fB\\-\\-output, \\-\\-quiet\\fR\nat most one may be set\n",
+		".SH EXAMPLES\n.PP\nApply all\n.PP\n.RS\n.nf\nmyapp db migrate \\-\\-output=out\n",
+		".SH SEE ALSO\n\\fBmyapp\\-db\\fR(1),\n\\fBmyapp\\-status\\fR(1)\n",
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("Expected %q in page:\n%s", want, page)
+		}
+	}
+	if strings.Contains(page, "secret") {
+		t.Errorf("Expected hidden flag to be left out:\n%s", page)
+	}
+}
+
+func TestGenManTree(t *testing.T) {
+	t.Setenv("SOURCE_DATE_EPOCH", "1767225600")
+	c := newTestCLI()
+	dir := t.TempDir()
+
+	if err := GenManTree(c, dir, ManOptions{Section: "8"}); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+	want := []string{"myapp-db-migrate.8", "myapp-db.8", "myapp-help.8", "myapp-status.8", "myapp.8"}
+	if strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Errorf("Unexpected pages %v, want %v", names, want)
+	}
+
+	root, err := os.ReadFile(filepath.Join(dir, "myapp.8"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(root), `"Jan 2026"`) || !strings.Contains(string(root), ".SH COMMANDS\n") || strings.Contains(string(root), "internal") {
+		t.Errorf("Unexpected root page:\n%s", root)
+	}
+}
+
+func TestManCommand(t *testing.T) {
+	c := newTestCLI()
+	if err := c.RegisterCommand(nil, NewManCommand(c, ManOptions{})); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := filepath.Join(t.TempDir(), "man")
+	if err := c.Run([]string{"gen-man", dir}); err != nil {
+		t.Fatalf("gen-man failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "myapp-db-migrate.1")); err != nil {
+		t.Errorf("Expected generated page: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "myapp-gen-man.1")); err == nil {
+		t.Error("Expected no page for the hidden gen-man command")
+	}
+}
+
+func TestGenMarkdownTree(t *testing.T) {
+	c := newTestCLI()
+	dir := t.TempDir()
+
+	if err := GenMarkdownTree(c, dir, MarkdownOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	migrate, err := os.ReadFile(filepath.Join(dir, "myapp-db-migrate.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"# myapp db migrate\n\nRun migrations\n\nRun pending migrations.",
+		"## Usage\n\n```\nmyapp db migrate [flags] [steps]\n```\n",
+		"Aliases: `mig`\n",
+		"## Flags\n\n- `-o, --output string`: output directory (default \"\")\n",
+		"## Global Flags\n\n- `-v, --verbose`: verbose output",
+		"## Examples\n\nApply all:\n\n```sh\nmyapp db migrate --output=out\n```\n",
+		"## See Also\n\n- [myapp db](myapp-db.md)\n- [myapp status](myapp-status.md)\n",
+	} {
+		if !strings.Contains(string(migrate), want) {
+			t.Errorf("Expected %q in page:\n%s", want, migrate)
+		}
+	}
+
+	db, err := os.ReadFile(filepath.Join(dir, "myapp-db.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(db), "## Commands\n\n- [migrate](myapp-db-migrate.md): Run migrations\n") {
+		t.Errorf("Expected linked subcommands:\n%s", db)
+	}
+	if strings.Contains(string(db), "[myapp db migrate]") {
+		t.Errorf("Expected subcommands not to be repeated under See Also:\n%s", db)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "myapp-internal.md")); err == nil {
+		t.Error("Expected no page for a hidden command")
+	}
+
+	var out bytes.Buffer
+	link := func(base string) string { return "/cli/" + base + "/" }
+	if err := GenMarkdown(c, c.Root, nil, &out, MarkdownOptions{Link: link}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "- [db](/cli/myapp-db/): Database operations") {
+		t.Errorf("Expected custom links:\n%s", out.String())
+	}
+}
+
+func TestGenHTML(t *testing.T) {
+	c := newTestCLI()
+	c.Root.Commands[0].Commands[0].Summary = "Run <all> migrations"
+
+	var out bytes.Buffer
+	if err := GenHTML(c, &out, HTMLOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	page := out.String()
+	for _, want := range []string{
+		"<title>myapp reference</title>",
+		`<input id="search" type="search"`,
+		`<li data-search="myapp db migrate Run &lt;all&gt; migrations"><a href="#myapp-db-migrate"`,
+		`<section id="myapp-db-migrate">`,
+		"<pre>.schema</pre>",
+		`<dt><a href="#myapp-db-migrate">migrate</a></dt>`,
+		`<li><a href="#myapp-status">myapp status</a></li>`,
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("Expected %q in page", want)
+		}
+	}
+	if strings.Contains(page, "internal") || strings.Contains(page, "Run <all>") {
+		t.Error("Expected hidden commands to be left out and text to be escaped")
+	}
+}
+
+func TestDocsIncludeLuaCommands(t *testing.T) {
+	c := newTestCLI()
+	engine := lua.NewEngine(c)
+	defer engine.Close()
+
+	if err := engine.DoString(`
+		command { name = "hello", summary = "Say hello", handler = function(ctx) end }
+		command { name = "secret", hidden = true, handler = function(ctx) end }
+	`); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	if err := GenMarkdownTree(c, dir, MarkdownOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "myapp-hello.md")); err != nil {
+		t.Errorf("Expected a page for the Lua command: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "myapp-secret.md")); err == nil {
+		t.Error("Expected no page for the hidden Lua command")
+	}
+}
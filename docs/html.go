@@ -0,0 +1,191 @@
+package docs
+
+import (
+	"html/template"
+	"io"
+
+	"github.com/kingoftac/flagon/cli"
+)
+
+// HTMLOptions controls the generated HTML reference.
+type HTMLOptions struct {
+	// Title of the page, "<root> reference" by default.
+	Title string
+}
+
+type htmlBlock struct {
+	Text string
+	Code bool
+}
+
+type htmlSeeAlso struct {
+	Path   string
+	Anchor string
+}
+
+type htmlPage struct {
+	*cli.HelpData
+	Anchor      string
+	Depth       int
+	Blocks      []htmlBlock
+	SubAnchors  map[string]string
+	SeeAlsoRefs []htmlSeeAlso
+}
+
+const htmlSource = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { margin: 0; font-family: system-ui, sans-serif; line-height: 1.5; color: #222; }
+nav { position: fixed; top: 0; bottom: 0; left: 0; width: 18rem; overflow-y: auto; padding: 1rem; background: #f6f6f6; border-right: 1px solid #ddd; box-sizing: border-box; }
+nav input { width: 100%; padding: .4rem; margin-bottom: .5rem; box-sizing: border-box; }
+nav ul { list-style: none; margin: 0; padding: 0; }
+nav li a { display: block; padding: .1rem 0; color: #0b5cad; text-decoration: none; font-family: ui-monospace, monospace; font-size: .9rem; }
+main { margin-left: 18rem; padding: 1rem 2rem; max-width: 60rem; }
+section { border-bottom: 1px solid #eee; padding-bottom: 1rem; }
+pre, code { font-family: ui-monospace, monospace; }
+pre { background: #f6f6f6; padding: .5rem .75rem; overflow-x: auto; }
+dt { font-family: ui-monospace, monospace; font-weight: bold; }
+dd { margin: 0 0 .4rem 1.5rem; }
+.summary { color: #555; }
+</style>
+</head>
+<body>
+<nav>
+<input id="search" type="search" placeholder="Search commands" aria-label="Search commands">
+<ul id="index">
+{{- range .Pages}}
+<li data-search="{{.Path}} {{.Summary}}"><a href="#{{.Anchor}}" style="padding-left: {{.Depth}}rem">{{.Path}}</a></li>
+{{- end}}
+</ul>
+</nav>
+<main>
+<h1>{{.Title}}</h1>
+{{- range .Pages}}
+<section id="{{.Anchor}}">
+<h2>{{.Path}}</h2>
+{{- with .Summary}}
+<p class="summary">{{.}}</p>
+{{- end}}
+{{- range .Blocks}}
+{{- if .Code}}
+<pre>{{.Text}}</pre>
+{{- else}}
+<p>{{.Text}}</p>
+{{- end}}
+{{- end}}
+<h3>Usage</h3>
+<pre>{{range $i, $u := .Usage}}{{if $i}}
+{{end}}{{$u}}{{end}}</pre>
+{{- with .Aliases}}
+<p>Aliases: {{range $i, $a := .}}{{if $i}}, {{end}}<code>{{$a}}</code>{{end}}</p>
+{{- end}}
+{{- template "entries" (entries "Arguments" .Args)}}
+{{- template "entries" (entries "Flags" .Flags)}}
+{{- template "entries" (entries "Global Flags" .GlobalFlags)}}
+{{- template "entries" (entries "Flag Rules" .FlagRules)}}
+{{- $page := .}}
+{{- range .CommandGroups}}
+<h3>{{.Title}}</h3>
+<dl>
+{{- range .Entries}}
+<dt><a href="#{{index $page.SubAnchors .Name}}">{{.Name}}</a></dt>
+<dd>{{.Usage}}</dd>
+{{- end}}
+</dl>
+{{- end}}
+{{- with .Examples}}
+<h3>Examples</h3>
+{{- range .}}
+{{- with .Description}}
+<p>{{.}}</p>
+{{- end}}
+<pre>{{.Invocation}}</pre>
+{{- end}}
+{{- end}}
+{{- with .SeeAlsoRefs}}
+<h3>See Also</h3>
+<ul>
+{{- range .}}
+<li>{{if .Anchor}}<a href="#{{.Anchor}}">{{.Path}}</a>{{else}}{{.Path}}{{end}}</li>
+{{- end}}
+</ul>
+{{- end}}
+</section>
+{{- end}}
+</main>
+<script>
+document.getElementById("search").addEventListener("input", function (e) {
+	var q = e.target.value.toLowerCase();
+	document.querySelectorAll("#index li").forEach(function (li) {
+		li.style.display = li.dataset.search.toLowerCase().indexOf(q) === -1 ? "none" : "";
+	});
+});
+</script>
+</body>
+</html>
+{{define "entries"}}
+{{- if .Entries}}
+<h3>{{.Title}}</h3>
+<dl>
+{{- range .Entries}}
+<dt>{{.Name}}</dt>
+<dd>{{.Usage}}</dd>
+{{- end}}
+</dl>
+{{- end}}
+{{- end}}`
+
+var htmlTemplate = template.Must(template.New("reference").Funcs(template.FuncMap{
+	"entries": func(title string, entries []cli.HelpEntry) cli.HelpSection {
+		return cli.HelpSection{Title: title, Entries: entries}
+	},
+}).Parse(htmlSource))
+
+// GenHTML writes a single-file HTML reference for every visible command of
+// c to w, with an index that can be filtered by typing in a search box.
+func GenHTML(c *cli.CLI, w io.Writer, opts HTMLOptions) error {
+	all, err := pages(c)
+	if err != nil {
+		return err
+	}
+	if opts.Title == "" {
+		opts.Title = c.Root.Name + " reference"
+	}
+
+	known := knownPaths(all)
+
+	data := struct {
+		Title string
+		Pages []htmlPage
+	}{Title: opts.Title}
+
+	for _, p := range all {
+		hp := htmlPage{
+			HelpData:   p.help,
+			Anchor:     baseName(p.help.Path),
+			Depth:      len(p.chain) - 1,
+			SubAnchors: map[string]string{},
+		}
+		blocks, code := paragraphs(p.help.Description)
+		for i, b := range blocks {
+			hp.Blocks = append(hp.Blocks, htmlBlock{Text: b, Code: code[i]})
+		}
+		for _, e := range p.help.Commands {
+			hp.SubAnchors[e.Name] = baseName(p.help.Path + " " + e.Name)
+		}
+		for _, ref := range related(p) {
+			rel := htmlSeeAlso{Path: ref}
+			if known[ref] {
+				rel.Anchor = baseName(ref)
+			}
+			hp.SeeAlsoRefs = append(hp.SeeAlsoRefs, rel)
+		}
+		data.Pages = append(data.Pages, hp)
+	}
+
+	return htmlTemplate.Execute(w, data)
+}
@@ -0,0 +1,232 @@
+package docs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kingoftac/flagon/cli"
+)
+
+// ManOptions controls the header of generated man pages.
+type ManOptions struct {
+	// Section is the manual section, "1" by default.
+	Section string
+	// Source is shown in the footer, e.g. "myapp 1.4.0".
+	Source string
+	// Manual is the title of the manual, "<root> Manual" by default.
+	Manual string
+	// Date is the date of the pages. It defaults to $SOURCE_DATE_EPOCH if
+	// set, for reproducible builds, and to the current time otherwise.
+	Date time.Time
+}
+
+func (o ManOptions) withDefaults(root string) ManOptions {
+	if o.Section == "" {
+		o.Section = "1"
+	}
+	if o.Manual == "" {
+		o.Manual = root + " Manual"
+	}
+	if o.Date.IsZero() {
+		o.Date = time.Now()
+		if epoch, err := strconv.ParseInt(os.Getenv("SOURCE_DATE_EPOCH"), 10, 64); err == nil {
+			o.Date = time.Unix(epoch, 0).UTC()
+		}
+	}
+	return o
+}
+
+// GenManTree writes one man page per visible command of c into dir, named
+// after the command path, e.g. myapp-db-migrate.1.
+func GenManTree(c *cli.CLI, dir string, opts ManOptions) error {
+	opts = opts.withDefaults(c.Root.Name)
+
+	all, err := pages(c)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	for _, p := range all {
+		name := filepath.Join(dir, baseName(p.help.Path)+"."+opts.Section)
+		f, err := os.Create(name)
+		if err != nil {
+			return err
+		}
+		writeMan(f, p, opts)
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GenMan writes the man page of cmd, whose ancestors starting at the root
+// are parents, to w.
+func GenMan(c *cli.CLI, cmd *cli.Command, parents []*cli.Command, w io.Writer, opts ManOptions) error {
+	help, err := c.HelpDataFor(cmd, parents)
+	if err != nil {
+		return err
+	}
+	chain := append(parents[:len(parents):len(parents)], cmd)
+	writeMan(w, page{chain: chain, help: help}, opts.withDefaults(c.Root.Name))
+	return nil
+}
+
+// NewManCommand returns a hidden "gen-man <dir>" command that writes the
+// man pages of c into dir. Register it with c.RegisterCommand(nil, ...).
+func NewManCommand(c *cli.CLI, opts ManOptions) *cli.Command {
+	return &cli.Command{
+		Name:        "gen-man",
+		Description: "Generate man pages for every command",
+		Hidden:      true,
+		Args: []cli.Arg{
+			{Name: "dir", Description: "Directory to write the pages to", Type: cli.PathFlag},
+		},
+		Handler: func(ctx context.Context) error {
+			return GenManTree(c, cli.ArgString(ctx, "dir"), opts)
+		},
+	}
+}
+
+func writeMan(w io.Writer, p page, opts ManOptions) {
+	h := p.help
+	name := baseName(h.Path)
+
+	fmt.Fprintf(w, ".TH %q %q %q %q %q\n", strings.ToUpper(name), opts.Section, opts.Date.Format("Jan 2006"), opts.Source, opts.Manual)
+	fmt.Fprintln(w, ".nh")
+	fmt.Fprintln(w, ".ad l")
+
+	fmt.Fprintln(w, ".SH NAME")
+	summary := h.Summary
+	if summary == "" {
+		summary, _, _ = strings.Cut(h.Command.Description, "\n")
+	}
+	if summary != "" {
+		fmt.Fprintf(w, "%s \\- %s\n", roffEscape(name), roffEscape(summary))
+	} else {
+		fmt.Fprintln(w, roffEscape(name))
+	}
+
+	fmt.Fprintln(w, ".SH SYNOPSIS")
+	for i, usage := range h.Usage {
+		if i > 0 {
+			fmt.Fprintln(w, ".br")
+		}
+		rest := strings.TrimPrefix(usage, h.Path)
+		fmt.Fprintf(w, "\\fB%s\\fR%s\n", roffEscape(h.Path), roffEscape(rest))
+	}
+
+	if h.Description != "" {
+		fmt.Fprintln(w, ".SH DESCRIPTION")
+		writeRoffText(w, h.Description)
+	}
+
+	if len(h.Aliases) > 0 {
+		fmt.Fprintln(w, ".SH ALIASES")
+		fmt.Fprintln(w, roffEscape(strings.Join(h.Aliases, ", ")))
+	}
+
+	writeRoffEntries(w, "ARGUMENTS", h.Args, false)
+	writeRoffEntries(w, "OPTIONS", h.Flags, true)
+	writeRoffEntries(w, "GLOBAL OPTIONS", h.GlobalFlags, true)
+	writeRoffEntries(w, "FLAG RULES", h.FlagRules, false)
+	writeRoffEntries(w, "COMMANDS", h.Commands, false)
+
+	if len(h.Examples) > 0 {
+		fmt.Fprintln(w, ".SH EXAMPLES")
+		for _, ex := range h.Examples {
+			if ex.Description != "" {
+				fmt.Fprintln(w, ".PP")
+				fmt.Fprintln(w, roffEscape(ex.Description))
+			}
+			fmt.Fprintln(w, ".PP")
+			fmt.Fprintln(w, ".RS")
+			fmt.Fprintln(w, ".nf")
+			fmt.Fprintln(w, roffEscape(ex.Invocation))
+			fmt.Fprintln(w, ".fi")
+			fmt.Fprintln(w, ".RE")
+		}
+	}
+
+	if refs := seeAlso(p); len(refs) > 0 {
+		fmt.Fprintln(w, ".SH SEE ALSO")
+		for i, ref := range refs {
+			sep := ","
+			if i == len(refs)-1 {
+				sep = ""
+			}
+			fmt.Fprintf(w, "\\fB%s\\fR(%s)%s\n", roffEscape(baseName(ref)), opts.Section, sep)
+		}
+	}
+}
+
+func writeRoffEntries(w io.Writer, title string, entries []cli.HelpEntry, bold bool) {
+	if len(entries) == 0 {
+		return
+	}
+	fmt.Fprintf(w, ".SH %s\n", title)
+	for _, e := range entries {
+		fmt.Fprintln(w, ".TP")
+		name := roffEscape(strings.TrimSpace(e.Name))
+		if bold {
+			// Flag names are bold, their value placeholders italic.
+			flags, placeholder, ok := cutPlaceholder(name)
+			name = "\\fB" + flags + "\\fR"
+			if ok {
+				name += " \\fI" + placeholder + "\\fR"
+			}
+		} else {
+			name = "\\fB" + name + "\\fR"
+		}
+		fmt.Fprintln(w, name)
+		fmt.Fprintln(w, roffEscape(e.Usage))
+	}
+}
+
+// cutPlaceholder splits "-o, \-\-output string" into the flag spellings and
+// the value placeholder.
+func cutPlaceholder(name string) (string, string, bool) {
+	i := strings.LastIndex(name, " ")
+	if i < 0 || strings.HasPrefix(name[i+1:], "\\-") {
+		return name, "", false
+	}
+	return name[:i], name[i+1:], true
+}
+
+func writeRoffText(w io.Writer, text string) {
+	blocks, code := paragraphs(text)
+	for i, block := range blocks {
+		fmt.Fprintln(w, ".PP")
+		if code[i] {
+			fmt.Fprintln(w, ".RS")
+			fmt.Fprintln(w, ".nf")
+			fmt.Fprintln(w, roffEscape(block))
+			fmt.Fprintln(w, ".fi")
+			fmt.Fprintln(w, ".RE")
+			continue
+		}
+		fmt.Fprintln(w, roffEscape(block))
+	}
+}
+
+// roffEscape escapes text for use in a roff document: backslashes and
+// dashes are escaped and lines may not start with a control character.
+func roffEscape(s string) string {
+	s = strings.NewReplacer(`\`, `\e`, "-", `\-`).Replace(s)
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, ".") || strings.HasPrefix(line, "'") {
+			lines[i] = `\&` + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
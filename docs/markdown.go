@@ -0,0 +1,140 @@
+package docs
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kingoftac/flagon/cli"
+)
+
+// MarkdownOptions controls generated markdown pages.
+type MarkdownOptions struct {
+	// Link turns the base name of a page, e.g. "myapp-db-migrate", into the
+	// target of a cross link. It defaults to appending ".md".
+	Link func(base string) string
+}
+
+func (o MarkdownOptions) link(path string) string {
+	if o.Link != nil {
+		return o.Link(baseName(path))
+	}
+	return baseName(path) + ".md"
+}
+
+// GenMarkdownTree writes one markdown page per visible command of c into
+// dir, named after the command path, e.g. myapp-db-migrate.md. Pages link
+// to their parent, subcommands and SeeAlso references.
+func GenMarkdownTree(c *cli.CLI, dir string, opts MarkdownOptions) error {
+	all, err := pages(c)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	known := knownPaths(all)
+
+	for _, p := range all {
+		f, err := os.Create(filepath.Join(dir, baseName(p.help.Path)+".md"))
+		if err != nil {
+			return err
+		}
+		writeMarkdown(f, p, known, opts)
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GenMarkdown writes the markdown page of cmd, whose ancestors starting at
+// the root are parents, to w.
+func GenMarkdown(c *cli.CLI, cmd *cli.Command, parents []*cli.Command, w io.Writer, opts MarkdownOptions) error {
+	all, err := pages(c)
+	if err != nil {
+		return err
+	}
+	known := knownPaths(all)
+
+	help, err := c.HelpDataFor(cmd, parents)
+	if err != nil {
+		return err
+	}
+	chain := append(parents[:len(parents):len(parents)], cmd)
+	writeMarkdown(w, page{chain: chain, help: help}, known, opts)
+	return nil
+}
+
+func writeMarkdown(w io.Writer, p page, known map[string]bool, opts MarkdownOptions) {
+	h := p.help
+
+	fmt.Fprintf(w, "# %s\n\n", h.Path)
+	if h.Summary != "" {
+		fmt.Fprintf(w, "%s\n\n", h.Summary)
+	}
+	if h.Description != "" {
+		fmt.Fprintf(w, "%s\n\n", strings.TrimSpace(h.Description))
+	}
+
+	fmt.Fprintf(w, "## Usage\n\n```\n%s\n```\n\n", strings.Join(h.Usage, "\n"))
+
+	if len(h.Aliases) > 0 {
+		fmt.Fprintf(w, "Aliases: `%s`\n\n", strings.Join(h.Aliases, "`, `"))
+	}
+
+	writeMarkdownEntries(w, "Arguments", h.Args)
+	writeMarkdownEntries(w, "Flags", h.Flags)
+	writeMarkdownEntries(w, "Global Flags", h.GlobalFlags)
+	writeMarkdownEntries(w, "Flag Rules", h.FlagRules)
+
+	for _, group := range h.CommandGroups {
+		fmt.Fprintf(w, "## %s\n\n", group.Title)
+		for _, e := range group.Entries {
+			path := h.Path + " " + e.Name
+			fmt.Fprintf(w, "- [%s](%s): %s\n", e.Name, opts.link(path), markdownEscape(e.Usage))
+		}
+		fmt.Fprintln(w)
+	}
+
+	if len(h.Examples) > 0 {
+		fmt.Fprint(w, "## Examples\n\n")
+		for _, ex := range h.Examples {
+			if ex.Description != "" {
+				fmt.Fprintf(w, "%s:\n\n", ex.Description)
+			}
+			fmt.Fprintf(w, "```sh\n%s\n```\n\n", ex.Invocation)
+		}
+	}
+
+	if refs := related(p); len(refs) > 0 {
+		fmt.Fprint(w, "## See Also\n\n")
+		for _, ref := range refs {
+			if known[ref] {
+				fmt.Fprintf(w, "- [%s](%s)\n", ref, opts.link(ref))
+			} else {
+				fmt.Fprintf(w, "- %s\n", ref)
+			}
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+func writeMarkdownEntries(w io.Writer, title string, entries []cli.HelpEntry) {
+	if len(entries) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "## %s\n\n", title)
+	for _, e := range entries {
+		fmt.Fprintf(w, "- `%s`: %s\n", strings.TrimSpace(e.Name), markdownEscape(e.Usage))
+	}
+	fmt.Fprintln(w)
+}
+
+// markdownEscape keeps usage text from being read as markdown markup.
+func markdownEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "*", `\*`, "_", `\_`, "`", "\\`", "<", "&lt;", "[", `\[`).Replace(s)
+}
@@ -2,6 +2,7 @@ package lua
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/kingoftac/flagon/cli"
 	lua "github.com/yuin/gopher-lua"
@@ -17,6 +18,12 @@ func newLuaContext(ctx interface{}, L *lua.LState) *lua.LTable {
 
 	t.RawSetString("args", args)
 
+	flags := L.NewTable()
+	for name, v := range cli.Flags(ctx.(context.Context)) {
+		flags.RawSetString(name, toLuaValue(v))
+	}
+	t.RawSetString("flags", flags)
+
 	app := cli.AppFromContext(ctx.(context.Context))
 	t.RawSetString("log", L.NewFunction(func(L *lua.LState) int {
 		level := L.CheckString(1)
@@ -61,3 +68,23 @@ func luaMiddleware(fn *lua.LFunction, L *lua.LState) cli.Middleware {
 		}
 	}
 }
+
+func toLuaValue(v any) lua.LValue {
+	switch v := v.(type) {
+	case nil:
+		return lua.LNil
+	case bool:
+		return lua.LBool(v)
+	case string:
+		return lua.LString(v)
+	case int:
+		return lua.LNumber(v)
+	case int64:
+		return lua.LNumber(v)
+	case float64:
+		return lua.LNumber(v)
+	case fmt.Stringer:
+		return lua.LString(v.String())
+	}
+	return lua.LString(fmt.Sprint(v))
+}
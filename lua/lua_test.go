@@ -4,6 +4,7 @@ import (
 	"context"
 	"testing"
 
+	"github.com/kingoftac/flagon/cli"
 	lua "github.com/yuin/gopher-lua"
 )
 
@@ -149,3 +150,50 @@ func TestGetBoolField(t *testing.T) {
 		t.Error("Expected false for missing key")
 	}
 }
+
+func TestDecodeCommandFlags(t *testing.T) {
+	L := lua.NewState()
+	defer L.Close()
+
+	if err := L.DoString(`spec = {
+		name = "build",
+		args = {
+			{ name = "target", choices = { "linux", "darwin" } },
+			{ name = "count", type = "int", optional = true, default = 1 },
+		},
+		flags = {
+			{ name = "output", type = "string", default = "dist", usage = "output dir", env = "OUT_DIR" },
+			{ name = "jobs", type = "int", default = 4, required = true },
+			{ name = "trace", type = "bool", hidden = true },
+		},
+	}`); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd, err := decodeCommand(L, L.GetGlobal("spec").(*lua.LTable))
+	if err != nil {
+		t.Fatalf("decodeCommand failed: %v", err)
+	}
+
+	if len(cmd.FlagDefs) != 3 {
+		t.Fatalf("Expected 3 flags, got %d", len(cmd.FlagDefs))
+	}
+
+	output := cmd.FlagDefs[0]
+	if output.Name != "output" || output.Type != cli.StringFlag || output.Default != "dist" || output.Usage != "output dir" || len(output.Env) != 1 {
+		t.Errorf("Unexpected output flag: %+v", output)
+	}
+
+	jobs := cmd.FlagDefs[1]
+	if jobs.Type != cli.IntFlag || jobs.Default != float64(4) || !jobs.Required {
+		t.Errorf("Unexpected jobs flag: %+v", jobs)
+	}
+
+	if !cmd.FlagDefs[2].Hidden {
+		t.Error("Expected trace flag to be hidden")
+	}
+
+	if len(cmd.Args) != 2 || len(cmd.Args[0].Choices) != 2 || cmd.Args[1].Type != cli.IntFlag || cmd.Args[1].Default != float64(1) {
+		t.Errorf("Unexpected args: %+v", cmd.Args)
+	}
+}
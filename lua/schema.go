@@ -14,6 +14,10 @@ func decodeCommand(L *lua.LState, t *lua.LTable) (*cli.Command, error) {
 	cmd := &cli.Command{
 		Name:        name,
 		Description: desc,
+		Summary:     getStringField(t, "summary", false),
+		Hidden:      getBoolField(t, "hidden"),
+		Deprecated:  getStringField(t, "deprecated", false),
+		RemoveIn:    getStringField(t, "remove_in", false),
 	}
 
 	if args := t.RawGetString("args"); args != lua.LNil {
@@ -25,10 +29,16 @@ func decodeCommand(L *lua.LState, t *lua.LTable) (*cli.Command, error) {
 				Description: getStringField(at, "description", false),
 				Optional:    getBoolField(at, "optional"),
 				Variadic:    getBoolField(at, "variadic"),
+				Type:        cli.FlagType(getStringField(at, "type", false)),
+				Default:     getAnyField(at, "default"),
+				Choices:     getStringListField(at, "choices"),
 			})
 		})
 	}
 
+	cmd.FlagDefs = decodeFlags(t, "flags")
+	cmd.PersistentFlags = decodeFlags(t, "persistent_flags")
+
 	if h := t.RawGetString("handler"); h != lua.LNil {
 		fn := h.(*lua.LFunction)
 		cmd.Handler = luaHandler(fn, L)
@@ -45,6 +55,33 @@ func decodeCommand(L *lua.LState, t *lua.LTable) (*cli.Command, error) {
 	return cmd, nil
 }
 
+func decodeFlags(t *lua.LTable, key string) []cli.Flag {
+	v := t.RawGetString(key)
+	if v == lua.LNil {
+		return nil
+	}
+
+	var flags []cli.Flag
+	v.(*lua.LTable).ForEach(func(_ lua.LValue, v lua.LValue) {
+		ft := v.(*lua.LTable)
+		flags = append(flags, cli.Flag{
+			Name:     getStringField(ft, "name", true),
+			Short:    getStringField(ft, "short", false),
+			Env:      getStringListField(ft, "env"),
+			Type:     cli.FlagType(getStringField(ft, "type", false)),
+			Default:  getAnyField(ft, "default"),
+			Usage:    getStringField(ft, "usage", false),
+			Required: getBoolField(ft, "required"),
+			Hidden:   getBoolField(ft, "hidden"),
+			Choices:  getStringListField(ft, "choices"),
+
+			Deprecated: getStringField(ft, "deprecated", false),
+			RemoveIn:   getStringField(ft, "remove_in", false),
+		})
+	})
+	return flags
+}
+
 func getStringField(t *lua.LTable, key string, required bool) string {
 	v := t.RawGetString(key)
 	if v == lua.LNil {
@@ -56,6 +93,21 @@ func getStringField(t *lua.LTable, key string, required bool) string {
 	return v.String()
 }
 
+// getStringListField accepts either a single string or an array of strings.
+func getStringListField(t *lua.LTable, key string) []string {
+	switch v := t.RawGetString(key).(type) {
+	case lua.LString:
+		return []string{string(v)}
+	case *lua.LTable:
+		var out []string
+		v.ForEach(func(_ lua.LValue, item lua.LValue) {
+			out = append(out, item.String())
+		})
+		return out
+	}
+	return nil
+}
+
 func getBoolField(t *lua.LTable, key string) bool {
 	v := t.RawGetString(key)
 	if v == lua.LNil {
@@ -63,3 +115,15 @@ func getBoolField(t *lua.LTable, key string) bool {
 	}
 	return lua.LVAsBool(v)
 }
+
+func getAnyField(t *lua.LTable, key string) any {
+	switch v := t.RawGetString(key).(type) {
+	case lua.LBool:
+		return bool(v)
+	case lua.LNumber:
+		return float64(v)
+	case lua.LString:
+		return string(v)
+	}
+	return nil
+}
@@ -67,26 +67,8 @@ func loadLuaPlugin(script string) interface{} {
 		return `{"error": "No command found in Lua script"}`
 	}
 
-	// Serialize the command struct to a map
-	cmd := engine.LastCommand
-	args := make([]map[string]interface{}, len(cmd.Args))
-	for i, arg := range cmd.Args {
-		args[i] = map[string]interface{}{
-			"name":        arg.Name,
-			"description": arg.Description,
-			"optional":    arg.Optional,
-			"variadic":    arg.Variadic,
-		}
-	}
-
-	data := map[string]interface{}{
-		"name":        cmd.Name,
-		"description": cmd.Description,
-		"summary":     cmd.Summary,
-		"hidden":      cmd.Hidden,
-		"aliases":     cmd.Aliases,
-		"args":        args,
-	}
+	// Serialize the command through the same spec the __spec command emits
+	data := engine.LastCommand.Spec()
 
 	jsonData, err := json.Marshal(data)
 	if err != nil {